@@ -0,0 +1,111 @@
+package conl
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A ByteSize is a number of bytes that can be written with a unit, for
+// example "512MiB" or "2GB". Decimal (kB, MB, GB, TB, PB, EB) and binary
+// (KiB, MiB, GiB, TiB, PiB, EiB) units are supported, units are
+// case-insensitive, and a plain number (optionally followed by "B") is a
+// number of bytes.
+type ByteSize int64
+
+// Common byte sizes.
+const (
+	Byte ByteSize = 1
+
+	KB ByteSize = 1000 * Byte
+	MB ByteSize = 1000 * KB
+	GB ByteSize = 1000 * MB
+	TB ByteSize = 1000 * GB
+	PB ByteSize = 1000 * TB
+	EB ByteSize = 1000 * PB
+
+	KiB ByteSize = 1024 * Byte
+	MiB ByteSize = 1024 * KiB
+	GiB ByteSize = 1024 * MiB
+	TiB ByteSize = 1024 * GiB
+	PiB ByteSize = 1024 * TiB
+	EiB ByteSize = 1024 * PiB
+)
+
+// byteSizeUnits are ordered from largest to smallest.
+var byteSizeUnits = []struct {
+	name string
+	size ByteSize
+}{
+	{"EiB", EiB}, {"EB", EB}, {"PiB", PiB}, {"PB", PB}, {"TiB", TiB}, {"TB", TB},
+	{"GiB", GiB}, {"GB", GB}, {"MiB", MiB}, {"MB", MB}, {"KiB", KiB}, {"kB", KB},
+	{"B", Byte},
+}
+
+// ParseByteSize parses a string like "512MiB" or "1.5GB". Fractional
+// sizes are rounded down to a whole number of bytes.
+func ParseByteSize(s string) (ByteSize, error) {
+	number := strings.TrimRight(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	unit := s[len(number):]
+	number = strings.TrimRight(number, " ")
+
+	size := Byte
+	if unit != "" {
+		found := false
+		for _, u := range byteSizeUnits {
+			if strings.EqualFold(unit, u.name) {
+				size, found = u.size, true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid byte size %q: unknown unit %q", s, unit)
+		}
+	}
+
+	if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+		if n > math.MaxInt64/int64(size) || n < math.MinInt64/int64(size) {
+			return 0, fmt.Errorf("invalid byte size %q: out of range", s)
+		}
+		return ByteSize(n) * size, nil
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	f *= float64(size)
+	if f >= math.MaxInt64 || f < math.MinInt64 {
+		return 0, fmt.Errorf("invalid byte size %q: out of range", s)
+	}
+	return ByteSize(f), nil
+}
+
+// String formats the size using the unit that gives the smallest whole
+// number, for example "512MiB", "2GB" or "100B".
+func (b ByteSize) String() string {
+	if b == 0 {
+		return "0B"
+	}
+	for _, u := range byteSizeUnits {
+		if b%u.size == 0 {
+			return strconv.FormatInt(int64(b/u.size), 10) + u.name
+		}
+	}
+	panic("unreachable")
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	size, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
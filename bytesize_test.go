@@ -0,0 +1,67 @@
+package conl_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ConradIrwin/conl-go"
+)
+
+func TestByteSize(t *testing.T) {
+	for _, tt := range []struct {
+		input    string
+		expected conl.ByteSize
+		output   string
+	}{
+		{"512MiB", 512 * conl.MiB, "512MiB"},
+		{"2GB", 2 * conl.GB, "2GB"},
+		{"2 gb", 2 * conl.GB, "2GB"},
+		{"1.5KiB", 1536, "1536B"},
+		{"1024", conl.KiB, "1KiB"},
+		{"100B", 100, "100B"},
+		{"0", 0, "0B"},
+		{"8EiB", 0, ""},
+		{"12 parsecs", 0, ""},
+		{"MB", 0, ""},
+	} {
+		size, err := conl.ParseByteSize(tt.input)
+		if tt.output == "" {
+			if err == nil {
+				t.Errorf("%s: expected error, got %v", tt.input, size)
+			}
+			continue
+		}
+		if err != nil || size != tt.expected || size.String() != tt.output {
+			t.Errorf("%s: expected %d (%s), got %d (%s), %v", tt.input, tt.expected, tt.output, size, size, err)
+		}
+	}
+}
+
+func TestDurationAndByteSize(t *testing.T) {
+	type config struct {
+		Timeout time.Duration `conl:"timeout,default=30s"`
+		Retry   time.Duration `conl:"retry"`
+		Cache   conl.ByteSize `conl:"cache"`
+		Limits  map[time.Duration]conl.ByteSize
+	}
+	var output config
+	if err := conl.Unmarshal([]byte("retry = 1m30s\ncache = 512MiB\nlimits\n  1h = 2GB\n"), &output); err != nil {
+		t.Fatal(err)
+	}
+	if output.Timeout != 30*time.Second || output.Retry != 90*time.Second || output.Cache != 512*conl.MiB || output.Limits[time.Hour] != 2*conl.GB {
+		t.Fatalf("unexpected output: %#v", output)
+	}
+
+	bytes, err := conl.Marshal(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "timeout = 30s\nretry = 1m30s\ncache = 512MiB\nLimits\n  1h0m0s = 2GB\n"
+	if string(bytes) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, bytes)
+	}
+
+	if err := conl.Unmarshal([]byte("retry = soon"), &output); err == nil || err.Error() != `1: retry: time: invalid duration "soon"` {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
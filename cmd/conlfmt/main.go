@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ConradIrwin/conl-go"
+)
+
+var (
+	write = flag.Bool("w", false, "write result to (source) file instead of stdout")
+	list  = flag.Bool("l", false, "list files whose formatting differs from conlfmt's")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: conlfmt [-l] [-w] [path ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if len(flag.Args()) == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "Error: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+			os.Exit(2)
+		}
+		if err := process("<standard input>", input, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "<standard input>:%v\n", err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	statusCode := 0
+	for _, path := range flag.Args() {
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || (file != path && filepath.Ext(file) != ".conl") {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			input, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			if err := process(file, input, info.Mode().Perm()); err != nil {
+				fmt.Fprintf(os.Stderr, "%v:%v\n", file, err)
+				statusCode = 2
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			statusCode = 2
+		}
+	}
+	os.Exit(statusCode)
+}
+
+func process(file string, input []byte, perm fs.FileMode) error {
+	output, err := conl.Format(input)
+	if err != nil {
+		return err
+	}
+
+	changed := !bytes.Equal(input, output)
+	if *list && changed {
+		fmt.Println(file)
+	}
+	if *write && changed {
+		if err := os.WriteFile(file, output, perm); err != nil {
+			return err
+		}
+	}
+	if !*list && !*write {
+		_, err = os.Stdout.Write(output)
+	}
+	return err
+}
@@ -1,7 +1,6 @@
 package conl
 
 import (
-	"fmt"
 	"iter"
 	"regexp"
 	"strconv"
@@ -54,26 +53,43 @@ func (k TokenKind) GoString() string {
 	return k.String()
 }
 
+// A Token is a single syntactic element of a CONL document.
+//
+// Tokens synthesized by the parser ([NoValue], [Outdent] and the tokens that
+// report structural errors) are positioned at the token that caused them to be
+// emitted. [NoValue] and [Outdent] are always zero-width.
 type Token struct {
 	Lno     int
 	Kind    TokenKind
 	Content string
 	Error   error
+
+	// Col is the 1-based byte offset of the start of the token within line Lno.
+	Col int
+	// Start and End are the byte offsets of the token within the document.
+	// For quoted keys and values, the span includes the quotes; for comments,
+	// it includes the leading ';'.
+	Start, End int
+	// EndLno is the line on which the token ends. It differs from Lno only
+	// for a [MultilineScalar] that spans several lines.
+	EndLno int
 }
 
 var lineRegexp = regexp.MustCompile("\r\n|\r|\n")
 
+// lines iterates over the lines in input, yielding the byte offset
+// at which each line starts along with its content.
 func lines(input string) iter.Seq2[int, string] {
 	return func(yield func(int, string) bool) {
-		lno := 1
+		start := 0
 		for match := lineRegexp.FindStringIndex(input); match != nil; match = lineRegexp.FindStringIndex(input) {
-			if !yield(lno, input[:match[0]]) {
+			if !yield(start, input[:match[0]]) {
 				return
 			}
 			input = input[match[1]:]
-			lno++
+			start += match[1]
 		}
-		yield(lno, input)
+		yield(start, input)
 	}
 }
 
@@ -127,7 +143,7 @@ var (
 
 func decodeLiteral(input string) (string, error) {
 	if !utf8.ValidString(input) {
-		return "", fmt.Errorf("invalid UTF-8")
+		return "", syntaxError(InvalidUTF8, "invalid UTF-8")
 	}
 	if !strings.HasPrefix(input, `"`) {
 		return input, nil
@@ -135,10 +151,10 @@ func decodeLiteral(input string) (string, error) {
 
 	match := literalRegex.FindStringSubmatch(input)
 	if match == nil {
-		return "", fmt.Errorf("unclosed quotes")
+		return "", syntaxError(UnclosedQuotes, "unclosed quotes")
 	}
 	if len(match[0]) != len(input) {
-		return "", fmt.Errorf("characters after quotes")
+		return "", syntaxError(CharactersAfterQuotes, "characters after quotes")
 	}
 
 	var badEscape string
@@ -168,159 +184,201 @@ func decodeLiteral(input string) (string, error) {
 		return escape
 	})
 	if badEscape != "" {
-		return "", fmt.Errorf("invalid escape code: %s", badEscape)
+		return "", syntaxError(InvalidEscape, "invalid escape code: %s", badEscape)
 	}
 	return result, nil
 }
 
 func checkUtf8(content string) error {
 	if !utf8.ValidString(content) {
-		return fmt.Errorf("invalid UTF-8")
+		return syntaxError(InvalidUTF8, "invalid UTF-8")
 	}
 	return nil
 }
 
-func tokenize(input string) iter.Seq[Token] {
-	return func(yield func(Token) bool) {
-
-		stack := []string{""}
-		multiline := false
-		multilinePrefix := ""
-		multilineValue := ""
-		multilineLno := 0
-
-		for lno, content := range lines(input) {
-			rest := strings.TrimLeft(content, " \t")
-			indent := content[0 : len(content)-len(rest)]
-
-			if multiline {
-				if multilinePrefix == "" {
-					if strings.HasPrefix(indent, stack[len(stack)-1]) && indent != stack[len(stack)-1] {
-						multilinePrefix = indent
-						multilineValue = rest
-						multilineLno = lno
-						continue
-					} else if rest == "" {
-						continue
-					} else {
-						if !yield(Token{Lno: multilineLno, Kind: MultilineScalar, Error: fmt.Errorf("missing multiline value")}) {
-							return
-						}
-						multiline = false
-					}
-				} else {
-					if rest, found := strings.CutPrefix(content, multilinePrefix); found {
-						multilineValue += "\n" + rest
-						continue
-					} else if rest == "" {
-						multilineValue += "\n"
-						continue
-					} else {
-						content := strings.TrimRight(multilineValue, " \t\r\n")
-						err := checkUtf8(content)
-						if !yield(Token{Lno: multilineLno, Kind: MultilineScalar, Content: content, Error: err}) {
-							return
-						}
-						multiline = false
-						multilinePrefix = ""
-						multilineValue = ""
-					}
-				}
-			}
+// tokenizer splits lines of a CONL document into raw tokens.
+// It is fed one line at a time so that it can be used both for complete
+// documents and for streams.
+type tokenizer struct {
+	stack           []string
+	multiline       bool
+	multilinePrefix string
+	multilineValue  string
+	multilineLno    int
+	// the position of the multiline value (or of the hint, until the
+	// first line of the value is found)
+	multilineCol    int
+	multilineStart  int
+	multilineEnd    int
+	multilineEndLno int
+}
 
-			if rest == "" {
-				continue
-			}
+func newTokenizer() *tokenizer {
+	return &tokenizer{stack: []string{""}}
+}
 
-			if comment, found := strings.CutPrefix(rest, ";"); found {
-				if !yield(Token{Lno: lno, Kind: Comment, Content: comment, Error: checkUtf8(comment)}) {
-					return
-				}
-				continue
-			}
+// line tokenizes the line numbered lno, which starts at byte offset start.
+// It returns false if yield did.
+func (t *tokenizer) line(lno int, start int, content string, yield func(Token) bool) bool {
+	rest := strings.TrimLeft(content, " \t")
+	indent := content[0 : len(content)-len(rest)]
 
-			for !strings.HasPrefix(indent, stack[len(stack)-1]) {
-				stack = stack[:len(stack)-1]
-				if !yield(Token{Lno: lno, Kind: Outdent, Content: ""}) {
-					return
-				}
-			}
-
-			if indent != stack[len(stack)-1] {
-				stack = append(stack, indent)
-				if !yield(Token{Lno: lno, Kind: Indent, Content: indent}) {
-					return
+	// pos returns the offset of s, which must be a suffix of content
+	pos := func(s string) int {
+		return start + len(content) - len(s)
+	}
+	token := func(kind TokenKind, from, to int, content string, err error) Token {
+		return Token{Lno: lno, Kind: kind, Content: content, Error: positionError(err, lno, from-start+1), Col: from - start + 1, Start: from, End: to, EndLno: lno}
+	}
+	eol := start + len(content)
+
+	if t.multiline {
+		if t.multilinePrefix == "" {
+			if strings.HasPrefix(indent, t.stack[len(t.stack)-1]) && indent != t.stack[len(t.stack)-1] {
+				t.multilinePrefix = indent
+				t.multilineValue = rest
+				t.multilineLno = lno
+				t.multilineCol = len(indent) + 1
+				t.multilineStart = pos(rest)
+				t.multilineEnd = t.multilineStart + len(strings.TrimRight(rest, " \t"))
+				t.multilineEndLno = lno
+				return true
+			} else if rest == "" {
+				return true
+			} else {
+				if !yield(t.multilineToken("", syntaxError(MissingMultilineValue, "missing multiline value"))) {
+					return false
 				}
+				t.multiline = false
 			}
-
-			if list, found := strings.CutPrefix(rest, "="); found {
-				rest = strings.TrimLeft(list, " \t")
-				if !yield(Token{Lno: lno, Kind: ListItem, Content: ""}) {
-					return
+		} else {
+			if rest, found := strings.CutPrefix(content, t.multilinePrefix); found {
+				t.multilineValue += "\n" + rest
+				if trimmed := strings.TrimRight(rest, " \t"); trimmed != "" {
+					t.multilineEnd = start + len(t.multilinePrefix) + len(trimmed)
+					t.multilineEndLno = lno
 				}
+				return true
+			} else if rest == "" {
+				t.multilineValue += "\n"
+				return true
 			} else {
-				key, value := splitLiteral(rest, true)
-				content, err := decodeLiteral(key)
-				if !yield(Token{Lno: lno, Kind: MapKey, Content: content, Error: err}) {
-					return
+				content := strings.TrimRight(t.multilineValue, " \t\r\n")
+				if !yield(t.multilineToken(content, checkUtf8(content))) {
+					return false
 				}
-				rest = value
-				rest = strings.TrimLeft(value, " \t")
-				rest = strings.TrimPrefix(rest, "=")
-				rest = strings.TrimLeft(rest, " \t")
+				t.multiline = false
+				t.multilinePrefix = ""
+				t.multilineValue = ""
 			}
+		}
+	}
 
-			if comment, found := strings.CutPrefix(rest, ";"); found {
-				if !yield(Token{Lno: lno, Kind: Comment, Content: comment, Error: checkUtf8(comment)}) {
-					return
-				}
-				continue
-			}
+	if rest == "" {
+		return true
+	}
 
-			if indicator, found := strings.CutPrefix(rest, `"""`); found {
-				indicator, rest := splitLiteral(indicator, false)
-				multiline = true
-				multilineLno = lno
-				err := checkUtf8(indicator)
-				if strings.HasPrefix(indicator, "\"") {
-					err = fmt.Errorf("characters after quotes")
-				}
-				if !yield(Token{Lno: lno, Kind: MultilineHint, Content: indicator, Error: err}) {
-					return
-				}
+	if comment, found := strings.CutPrefix(rest, ";"); found {
+		return yield(token(Comment, pos(rest), eol, comment, checkUtf8(comment)))
+	}
 
-				if comment, found := strings.CutPrefix(rest, ";"); found {
-					if !yield(Token{Lno: lno, Kind: Comment, Content: comment, Error: checkUtf8(comment)}) {
-						return
-					}
-				}
-				continue
-			}
+	for !strings.HasPrefix(indent, t.stack[len(t.stack)-1]) {
+		t.stack = t.stack[:len(t.stack)-1]
+		if !yield(token(Outdent, pos(rest), pos(rest), "", nil)) {
+			return false
+		}
+	}
 
-			value, rest := splitLiteral(rest, false)
-			if value != "" {
-				content, err := decodeLiteral(value)
-				if !yield(Token{Lno: lno, Kind: Scalar, Content: content, Error: err}) {
-					return
-				}
-			}
+	if indent != t.stack[len(t.stack)-1] {
+		t.stack = append(t.stack, indent)
+		if !yield(token(Indent, start, pos(rest), indent, nil)) {
+			return false
+		}
+	}
 
-			if comment, found := strings.CutPrefix(rest, ";"); found {
-				if !yield(Token{Lno: lno, Kind: Comment, Content: comment, Error: checkUtf8(comment)}) {
-					return
-				}
-			}
+	if list, found := strings.CutPrefix(rest, "="); found {
+		if !yield(token(ListItem, pos(rest), pos(list), "", nil)) {
+			return false
 		}
+		rest = strings.TrimLeft(list, " \t")
+	} else {
+		key, value := splitLiteral(rest, true)
+		content, err := decodeLiteral(key)
+		if !yield(token(MapKey, pos(rest), pos(rest)+len(key), content, err)) {
+			return false
+		}
+		rest = strings.TrimLeft(value, " \t")
+		rest = strings.TrimPrefix(rest, "=")
+		rest = strings.TrimLeft(rest, " \t")
+	}
 
-		if multiline {
-			if multilineValue != "" {
-				content := strings.TrimRight(multilineValue, " \t\r\n")
-				yield(Token{Lno: multilineLno, Kind: MultilineScalar, Content: content, Error: checkUtf8(content)})
-			} else {
-				yield(Token{Lno: multilineLno, Kind: MultilineScalar, Error: fmt.Errorf("missing multiline value")})
-			}
+	if comment, found := strings.CutPrefix(rest, ";"); found {
+		return yield(token(Comment, pos(rest), eol, comment, checkUtf8(comment)))
+	}
+
+	if indicator, found := strings.CutPrefix(rest, `"""`); found {
+		hintStart := pos(rest)
+		indicator, rest := splitLiteral(indicator, false)
+		hintEnd := hintStart + len(`"""`) + len(indicator)
+		t.multiline = true
+		t.multilineLno = lno
+		t.multilineCol = hintEnd - start + 1
+		t.multilineStart = hintEnd
+		t.multilineEnd = hintEnd
+		t.multilineEndLno = lno
+		err := checkUtf8(indicator)
+		if strings.HasPrefix(indicator, "\"") {
+			err = syntaxError(CharactersAfterQuotes, "characters after quotes")
+		}
+		if !yield(token(MultilineHint, hintStart, hintEnd, indicator, err)) {
+			return false
+		}
+
+		if comment, found := strings.CutPrefix(rest, ";"); found {
+			return yield(token(Comment, pos(rest), eol, comment, checkUtf8(comment)))
+		}
+		return true
+	}
+
+	valueStart := pos(rest)
+	value, rest := splitLiteral(rest, false)
+	if value != "" {
+		content, err := decodeLiteral(value)
+		if !yield(token(Scalar, valueStart, valueStart+len(value), content, err)) {
+			return false
 		}
 	}
+
+	if comment, found := strings.CutPrefix(rest, ";"); found {
+		return yield(token(Comment, pos(rest), eol, comment, checkUtf8(comment)))
+	}
+	return true
+}
+
+func (t *tokenizer) multilineToken(content string, err error) Token {
+	return Token{
+		Lno:     t.multilineLno,
+		Kind:    MultilineScalar,
+		Content: content,
+		Error:   positionError(err, t.multilineLno, t.multilineCol),
+		Col:     t.multilineCol,
+		Start:   t.multilineStart,
+		End:     t.multilineEnd,
+		EndLno:  t.multilineEndLno,
+	}
+}
+
+// end flushes any multiline value that was still open at the end of input.
+func (t *tokenizer) end(yield func(Token) bool) bool {
+	if !t.multiline {
+		return true
+	}
+	t.multiline = false
+	if t.multilineValue != "" {
+		content := strings.TrimRight(t.multilineValue, " \t\r\n")
+		return yield(t.multilineToken(content, checkUtf8(content)))
+	}
+	return yield(t.multilineToken("", syntaxError(MissingMultilineValue, "missing multiline value")))
 }
 
 type parseState struct {
@@ -328,6 +386,109 @@ type parseState struct {
 	hasKey bool
 }
 
+// parser post-processes the raw tokens from a tokenizer to maintain
+// the invariants documented on [Tokens].
+type parser struct {
+	tokenizer *tokenizer
+	states    []parseState
+	last      Token
+}
+
+func newParser() *parser {
+	return &parser{tokenizer: newTokenizer(), states: []parseState{{}}}
+}
+
+// line parses the line numbered lno, which starts at byte offset start.
+// It returns false if yield did.
+func (p *parser) line(lno int, start int, content string, yield func(Token) bool) bool {
+	return p.tokenizer.line(lno, start, content, func(token Token) bool {
+		return p.process(token, yield)
+	})
+}
+
+// end flushes any remaining tokens at the end of the input.
+func (p *parser) end(yield func(Token) bool) {
+	if !p.tokenizer.end(func(token Token) bool { return p.process(token, yield) }) {
+		return
+	}
+
+	for len(p.states) > 0 {
+		state := p.states[len(p.states)-1]
+		if state.hasKey {
+			if !yield(synthesize(p.last, NoValue, nil)) {
+				return
+			}
+		}
+		if len(p.states) > 1 {
+			if !yield(synthesize(p.last, Outdent, nil)) {
+				return
+			}
+		}
+		p.states = p.states[:len(p.states)-1]
+	}
+}
+
+func (p *parser) process(token Token, yield func(Token) bool) bool {
+	state := &p.states[len(p.states)-1]
+	switch token.Kind {
+	case Indent:
+		if state.hasKey {
+			state.hasKey = false
+		} else {
+			kind := state.kind
+			if kind == 0 {
+				kind = MapKey
+			}
+			if !yield(synthesize(token, kind, syntaxError(UnexpectedIndent, "unexpected indent"))) {
+				return false
+			}
+		}
+		p.states = append(p.states, parseState{})
+	case Outdent:
+		p.states = p.states[:len(p.states)-1]
+		if state.hasKey {
+			if !yield(synthesize(token, NoValue, nil)) {
+				return false
+			}
+		}
+	case ListItem, MapKey:
+		if state.kind == 0 {
+			state.kind = token.Kind
+		}
+		if state.hasKey {
+			if !yield(synthesize(token, NoValue, nil)) {
+				return false
+			}
+		}
+		state.hasKey = true
+		if state.kind == MapKey && token.Kind == ListItem {
+			return yield(synthesize(token, MapKey, syntaxError(UnexpectedListItem, "unexpected list item")))
+		}
+		if state.kind == ListItem && token.Kind == MapKey {
+			return yield(synthesize(token, ListItem, syntaxError(UnexpectedMapKey, "unexpected map key")))
+		}
+	case Scalar, MultilineScalar:
+		state.hasKey = false
+
+	case Comment, MultilineHint:
+		// pass-through
+	default:
+		panic("Unknown token kind")
+	}
+	p.last = token
+	return yield(token)
+}
+
+// synthesize creates a token of the given kind positioned at at.
+// Tokens that report errors cover the same span as at, others are zero-width.
+func synthesize(at Token, kind TokenKind, err error) Token {
+	end := at.Start
+	if err != nil {
+		end = at.End
+	}
+	return Token{Lno: at.Lno, Kind: kind, Error: positionError(err, at.Lno, at.Col), Col: at.Col, Start: at.Start, End: end, EndLno: at.Lno}
+}
+
 // Tokens iterates over tokens in the input string.
 //
 // The raw tokens are post-processed to maintain the invariants that:
@@ -337,86 +498,19 @@ type parseState struct {
 //   - after a [MultilineHint] you will always get a [MultilineValue]
 //   - within a given section you will only find [ListItem] or [MapKey], not a mix.
 //
-// Any parse errors are reported in Token.Error. The parser is tolerant to errors,
+// Any parse errors are reported in Token.Error as a [*SyntaxError]. The parser is tolerant to errors,
 // though the resulting document may not be what the user intended, so you should
 // handle errors appropriately.
 func Tokens(input []byte) iter.Seq[Token] {
-	states := []parseState{{}}
-	lastLine := 0
-
 	return func(yield func(Token) bool) {
-		for token := range tokenize(string(input)) {
-			state := &states[len(states)-1]
-			switch token.Kind {
-			case Indent:
-				if state.hasKey {
-					state.hasKey = false
-				} else {
-					kind := state.kind
-					if kind == 0 {
-						kind = MapKey
-					}
-					if !yield(Token{Lno: token.Lno, Kind: kind, Error: fmt.Errorf("unexpected indent"), Content: ""}) {
-						return
-					}
-				}
-				states = append(states, parseState{})
-			case Outdent:
-				states = states[:len(states)-1]
-				if state.hasKey {
-					if !yield(Token{Lno: token.Lno, Kind: NoValue}) {
-						return
-					}
-				}
-			case ListItem, MapKey:
-				if state.kind == 0 {
-					state.kind = token.Kind
-				}
-				if state.hasKey {
-					if !yield(Token{Lno: token.Lno, Kind: NoValue}) {
-						return
-					}
-				}
-				state.hasKey = true
-				if state.kind == MapKey && token.Kind == ListItem {
-					if !yield(Token{Lno: token.Lno, Kind: MapKey, Error: fmt.Errorf("unexpected list item")}) {
-						return
-					}
-					continue
-				}
-				if state.kind == ListItem && token.Kind == MapKey {
-					if !yield(Token{Lno: token.Lno, Kind: ListItem, Error: fmt.Errorf("unexpected map key")}) {
-						return
-					}
-					continue
-				}
-			case Scalar, MultilineScalar:
-				state.hasKey = false
-
-			case Comment, MultilineHint:
-				// pass-through
-			default:
-				panic("Unknown token kind")
-			}
-			lastLine = token.Lno
-			if !yield(token) {
+		p := newParser()
+		lno := 0
+		for start, content := range lines(string(input)) {
+			lno++
+			if !p.line(lno, start, content, yield) {
 				return
 			}
 		}
-
-		for len(states) > 0 {
-			state := states[len(states)-1]
-			if state.hasKey {
-				if !yield(Token{Lno: lastLine, Kind: NoValue, Content: ""}) {
-					return
-				}
-			}
-			if len(states) > 1 {
-				if !yield(Token{Lno: lastLine, Kind: Outdent, Content: ""}) {
-					return
-				}
-			}
-			states = states[:len(states)-1]
-		}
+		p.end(yield)
 	}
 }
@@ -2,6 +2,7 @@ package conl_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"iter"
 	"os"
@@ -150,3 +151,88 @@ func FuzzParse(f *testing.F) {
 		}
 	})
 }
+
+func TestPositions(t *testing.T) {
+	input := "; header\r\nmap ; note\n  \"a b\" = \"c\"\n  d\nlist\n  =  e\ntext = \"\"\"sql ; hint\n    select 1\n\n    from t  \n"
+
+	expected := []string{
+		"1:1-1:9 Comment \"; header\"",
+		"2:1-2:4 MapKey \"map\"",
+		"2:5-2:11 Comment \"; note\"",
+		"3:1-3:3 Indent \"  \"",
+		"3:3-3:8 MapKey \"\\\"a b\\\"\"",
+		"3:11-3:14 Value \"\\\"c\\\"\"",
+		"4:3-4:4 MapKey \"d\"",
+		"5:1-5:1 NoValue \"\"",
+		"5:1-5:1 Outdent \"\"",
+		"5:1-5:5 MapKey \"list\"",
+		"6:1-6:3 Indent \"  \"",
+		"6:3-6:4 ListItem \"=\"",
+		"6:6-6:7 Value \"e\"",
+		"7:1-7:1 Outdent \"\"",
+		"7:1-7:5 MapKey \"text\"",
+		"7:8-7:14 MultilineHint \"\\\"\\\"\\\"sql\"",
+		"7:15-7:21 Comment \"; hint\"",
+		"8:5-10:11 MultilineValue \"select 1\\n\\n    from t\"",
+	}
+
+	actual := []string{}
+	for token := range conl.Tokens([]byte(input)) {
+		if token.Error != nil {
+			t.Fatalf("unexpected error: %v", token.Error)
+		}
+		endCol := token.Col + token.End - token.Start
+		if token.EndLno != token.Lno {
+			endCol = token.End - strings.LastIndex(input[:token.End], "\n")
+		}
+		actual = append(actual, fmt.Sprintf("%d:%d-%d:%d %s %#v", token.Lno, token.Col, token.EndLno, endCol, token.Kind, input[token.Start:token.End]))
+	}
+
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestCheck(t *testing.T) {
+	input := "a = \"b\n c = d\nlist\n  = x\n  y = \"\\q\"\n"
+
+	expected := []conl.SyntaxError{
+		{Lno: 1, Col: 5, Kind: conl.UnclosedQuotes, Msg: "unclosed quotes"},
+		{Lno: 2, Col: 1, Kind: conl.UnexpectedIndent, Msg: "unexpected indent"},
+		{Lno: 5, Col: 3, Kind: conl.UnexpectedMapKey, Msg: "unexpected map key"},
+		{Lno: 5, Col: 7, Kind: conl.InvalidEscape, Msg: "invalid escape code: \\q"},
+	}
+	errs := conl.Check([]byte(input))
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		var se *conl.SyntaxError
+		if !errors.As(err, &se) || *se != expected[i] {
+			t.Fatalf("expected %#v, got %#v", expected[i], err)
+		}
+	}
+
+	if errs[0].Error() != "1: unclosed quotes" {
+		t.Fatalf("expected line number in error, got %q", errs[0].Error())
+	}
+	for token := range conl.Tokens([]byte(input)) {
+		if token.Error != nil {
+			if token.Error.Error() != "unclosed quotes" {
+				t.Fatalf("expected token error without position, got %q", token.Error.Error())
+			}
+			break
+		}
+	}
+
+	if errs := conl.Check([]byte("a = b\n")); errs != nil {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+
+	var v any
+	err := conl.Unmarshal([]byte(input), &v)
+	var se *conl.SyntaxError
+	if !errors.As(err, &se) || se.Kind != conl.UnclosedQuotes || err.Error() != "1: unclosed quotes" {
+		t.Fatalf("expected first syntax error, got %#v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package conl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"iter"
+	"math"
+)
+
+// A Decoder reads a CONL document from an input stream.
+//
+// Unlike [Tokens] and [Unmarshal], a Decoder does not need the whole document
+// in memory: lines are tokenized as they are read, so it is suitable for large
+// files or for reading from pipes.
+type Decoder struct {
+	scanner *bufio.Scanner
+	parser  *parser
+	queue   []Token
+	lno     int
+	// offset is the number of bytes consumed by the scanner, and
+	// lineStart is the offset of the line it last returned.
+	offset    int
+	lineStart int
+	// terminated is true if the input so far is empty or ends with a line
+	// terminator, in which case [Tokens] sees a final empty line.
+	terminated bool
+	done       bool
+	err        error
+	opts       UnmarshalOptions
+}
+
+// NewDecoder returns a new decoder that reads from r.
+//
+// The decoder introduces its own buffering and may read data from r
+// beyond the end of the current line.
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), math.MaxInt32)
+	d := &Decoder{scanner: scanner, parser: newParser(), terminated: true}
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, line, err := scanLines(data, atEOF)
+		if line != nil {
+			d.lineStart = d.offset
+			d.terminated = advance > len(line)
+		}
+		d.offset += advance
+		return advance, line, err
+	})
+	return d
+}
+
+// scanLines is a [bufio.SplitFunc] that splits on "\r\n", "\r" or "\n",
+// matching the line endings accepted by [Tokens].
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// Token returns the next token in the input stream.
+// The tokens are the same as those yielded by [Tokens], and maintain the same invariants.
+// At the end of the input, Token returns [io.EOF]. Any other error is an error reading
+// from the underlying reader; parse errors are reported in Token.Error as usual.
+func (d *Decoder) Token() (Token, error) {
+	for len(d.queue) == 0 {
+		if d.err != nil {
+			return Token{}, d.err
+		}
+		if d.done {
+			return Token{}, io.EOF
+		}
+		d.fill()
+	}
+	token := d.queue[0]
+	d.queue = d.queue[1:]
+	return token, nil
+}
+
+func (d *Decoder) push(token Token) bool {
+	d.queue = append(d.queue, token)
+	return true
+}
+
+// fill reads the next line of input and queues any tokens it produces.
+func (d *Decoder) fill() {
+	if d.scanner.Scan() {
+		d.lno++
+		d.parser.line(d.lno, d.lineStart, d.scanner.Text(), d.push)
+		return
+	}
+	if err := d.scanner.Err(); err != nil {
+		d.err = err
+		return
+	}
+	d.done = true
+	if d.terminated {
+		d.lno++
+		d.parser.line(d.lno, d.offset, "", d.push)
+	}
+	d.parser.end(d.push)
+}
+
+// tokens iterates over the remaining tokens in the input stream.
+func (d *Decoder) tokens() iter.Seq[Token] {
+	return func(yield func(Token) bool) {
+		for {
+			token, err := d.Token()
+			if err != nil || !yield(token) {
+				return
+			}
+		}
+	}
+}
+
+// SetOptions sets the options used by [Decoder.Decode].
+func (d *Decoder) SetOptions(opts UnmarshalOptions) {
+	d.opts = opts
+}
+
+// Decode reads the rest of the input and stores the result in the value pointed to by v.
+// See the documentation for [Unmarshal] for details about the conversion.
+func (d *Decoder) Decode(v any) error {
+	err := unmarshalTokens(d.tokens(), v, d.opts)
+	if d.err != nil {
+		return d.err
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package conl_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ConradIrwin/conl-go"
+)
+
+func decoderTokens(t *testing.T, r io.Reader) []conl.Token {
+	t.Helper()
+	dec := conl.NewDecoder(r)
+	tokens := []conl.Token{}
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			return tokens
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+func TestDecoderTokens(t *testing.T) {
+	for _, file := range []string{"testdata/examples.txt", "testdata/errors.txt"} {
+		examples, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+
+		examplesStr := strings.ReplaceAll(string(examples), "␉", "\t")
+		examplesStr = strings.ReplaceAll(examplesStr, "␊", "\r")
+
+		inputs := []string{"a = \"\"\"\n  \n", "a = \"\"\"\n", "a = \"\"\"\r", "a\n  = \"\"\"\r\n    x\r\n"}
+		for _, example := range strings.Split(examplesStr, "\n===\n") {
+			input, _, _ := strings.Cut(example, "\n---\n")
+			inputs = append(inputs, strings.ReplaceAll(input, "?", "\xff"))
+		}
+		for _, input := range inputs {
+
+			expected := slices.Collect(conl.Tokens([]byte(input)))
+			for _, r := range []io.Reader{
+				strings.NewReader(input),
+				iotest.OneByteReader(strings.NewReader(input)),
+			} {
+				actual := decoderTokens(t, r)
+				if !reflect.DeepEqual(expected, actual) {
+					t.Fatalf("Mismatch:\nInput: %#v\nExpected: %#v\nGot: %#v", input, expected, actual)
+				}
+			}
+		}
+	}
+}
+
+func TestDecoderDecode(t *testing.T) {
+	type Config struct {
+		Name  string   `conl:"name"`
+		Tags  []string `conl:"tags"`
+		Notes string   `conl:"notes"`
+	}
+
+	input := "name = example\r\ntags\r  = a\r\n  = b\nnotes = \"\"\"\n  one\n  two\n"
+	config := Config{}
+	if err := conl.NewDecoder(iotest.OneByteReader(strings.NewReader(input))).Decode(&config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := Config{Name: "example", Tags: []string{"a", "b"}, Notes: "one\ntwo"}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("got %+v, want %+v", config, expected)
+	}
+
+	readErr := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader("name = example\n"), iotest.ErrReader(readErr))
+	if err := conl.NewDecoder(r).Decode(&config); !errors.Is(err, readErr) {
+		t.Fatalf("expected read error, got: %v", err)
+	}
+}
@@ -28,13 +28,17 @@
 //
 // If your type implements the [encoding.TextMarshaler] and [encoding.TextUnmarshaler] then CONL
 // will use that to convert between a scalar and your type, otherwise scalars are parsed using
-// the [strconv] package.
+// the [strconv] package. As a special case, [time.Duration] is written and parsed in the
+// format used by [time.ParseDuration] (for example "1m30s"), and [ByteSize] can be used
+// for sizes like "512MiB" or "2GB".
 //
 // Package conl supports a very similar set of Go types to [encoding/json]. In particular, any
 // string, number, or boolean value can be serialized; as can any struct, map, array, or slive
 // of such values. On the flip side, channels and functions cannot be serialized. Unlike json,
 // conl allows map keys to be numbers, bools, or arrays or structs of those types in addition to
-// strings.
+// strings. The fields of a struct key (or elements of an array key) are written in order,
+// separated by ", ", and any component that contains a comma is quoted. For example a key of
+// type struct{ Region string; Tier int } is written as "us-east, 2".
 //
 // [CONL]: https://conl.dev
 package conl
@@ -0,0 +1,264 @@
+package conl
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+)
+
+// A Document is a concrete syntax tree for a CONL document.
+//
+// Unlike the tokens yielded by [Tokens], a Document retains every byte of
+// the input: comments, blank lines, indentation, quoting, multiline hints and
+// line endings. Calling [Document.Bytes] on an unmodified document returns
+// exactly the input that was parsed.
+type Document struct {
+	Section
+}
+
+// A Section is a sequence of entries at the same level of indentation.
+// The top level of a [Document] is a Section, as is the nested value of any
+// [Entry] that is a map or a list.
+type Section struct {
+	Entries []*Entry
+	// Trailing contains any blank lines and comments after the last entry
+	// that are indented at least as far as the entries.
+	Trailing []Line
+}
+
+// A Line is a line of a document that is not part of an entry: a blank line,
+// a line containing only a comment, or a line of a multiline scalar.
+type Line struct {
+	Text    string
+	Newline string
+}
+
+// An Entry is a single map key or list item, along with its value.
+//
+// The fields contain the raw text of the document. For example the line
+// `  port = "80" ; http` is represented with Indent "  ", Key "port",
+// Separator " = ", Value `"80"`, and Comment " ; http".
+type Entry struct {
+	// Leading contains blank lines and comments that precede the entry.
+	Leading []Line
+	Indent  string
+	// Key is the key as written (including any quotes), or "=" for a list item.
+	Key string
+	// Separator is the text between the key and the value (usually " = ").
+	// If there is no value it contains any "=" that followed the key.
+	Separator string
+	// Value is the scalar as written (including any quotes), or `"""` followed by
+	// the hint for a multiline scalar. It is empty if the value is not a scalar.
+	Value string
+	// Comment is any whitespace and comment that follow the key or value.
+	Comment string
+	Newline string
+	// Multiline contains the lines of a multiline scalar.
+	Multiline []Line
+	// Section contains the nested map or list, if any.
+	Section *Section
+}
+
+// IsListItem returns true if the entry is an item in a list, and false
+// if it is a key in a map.
+func (e *Entry) IsListItem() bool {
+	return e.Key == "="
+}
+
+// KeyContent returns the unquoted key of the entry.
+// It returns "" for list items.
+func (e *Entry) KeyContent() string {
+	if e.IsListItem() {
+		return ""
+	}
+	content, _ := decodeLiteral(e.Key)
+	return content
+}
+
+// IsMultiline returns true if the value of the entry is a multiline scalar.
+func (e *Entry) IsMultiline() bool {
+	return strings.HasPrefix(e.Value, `"""`)
+}
+
+// Hint returns the hint of a multiline scalar.
+func (e *Entry) Hint() string {
+	if !e.IsMultiline() {
+		return ""
+	}
+	return e.Value[len(`"""`):]
+}
+
+// Content returns the unquoted value of the entry if it is a scalar,
+// with multiline scalars unindented as described by the CONL spec.
+func (e *Entry) Content() string {
+	if !e.IsMultiline() {
+		content, _ := decodeLiteral(e.Value)
+		return content
+	}
+
+	// the first line indented further than the key determines the
+	// indentation of the value; any blank lines before it are ignored.
+	prefix := ""
+	lines := []string{}
+	for _, line := range e.Multiline {
+		if prefix == "" {
+			indent := line.Text[:len(line.Text)-len(strings.TrimLeft(line.Text, " \t"))]
+			if strings.HasPrefix(indent, e.Indent) && indent != e.Indent {
+				prefix = indent
+				lines = append(lines, line.Text[len(indent):])
+			}
+			continue
+		}
+		rest, _ := strings.CutPrefix(line.Text, prefix)
+		lines = append(lines, rest)
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), " \t\r\n")
+}
+
+// Bytes returns the CONL document.
+func (d *Document) Bytes() []byte {
+	var b bytes.Buffer
+	d.Section.write(&b)
+	return b.Bytes()
+}
+
+func (s *Section) write(b *bytes.Buffer) {
+	for _, e := range s.Entries {
+		e.write(b)
+	}
+	writeLines(b, s.Trailing)
+}
+
+func (e *Entry) write(b *bytes.Buffer) {
+	writeLines(b, e.Leading)
+	b.WriteString(e.Indent)
+	b.WriteString(e.Key)
+	b.WriteString(e.Separator)
+	b.WriteString(e.Value)
+	b.WriteString(e.Comment)
+	b.WriteString(e.Newline)
+	writeLines(b, e.Multiline)
+	if e.Section != nil {
+		e.Section.write(b)
+	}
+}
+
+func writeLines(b *bytes.Buffer, lines []Line) {
+	for _, line := range lines {
+		b.WriteString(line.Text)
+		b.WriteString(line.Newline)
+	}
+}
+
+// splitLines splits input into lines, retaining the line endings.
+// Unlike [lines], an empty final line is omitted.
+func splitLines(input string) []Line {
+	result := []Line{}
+	for match := lineRegexp.FindStringIndex(input); match != nil; match = lineRegexp.FindStringIndex(input) {
+		result = append(result, Line{input[:match[0]], input[match[0]:match[1]]})
+		input = input[match[1]:]
+	}
+	if input != "" {
+		result = append(result, Line{input, ""})
+	}
+	return result
+}
+
+// ParseDocument parses a CONL document into a [Document].
+// It returns an error if the document is not valid CONL.
+func ParseDocument(input []byte) (*Document, error) {
+	doc := &Document{}
+	lines := splitLines(string(input))
+
+	stack := []*Section{&doc.Section}
+	var entry *Entry
+	// the 0-based index of the next line that has not been assigned to an entry
+	next := 0
+	pending := []Line{}
+	pops := 0
+	push := false
+	keyEnd := 0
+
+	// flush assigns the lines before line lno to pending, and then
+	// closes and opens any sections.
+	flush := func(lno int) {
+		for ; next < lno-1 && next < len(lines); next++ {
+			pending = append(pending, lines[next])
+		}
+		for ; pops > 0; pops-- {
+			closed := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			n := trailingLines(pending, closed.Entries[0].Indent)
+			closed.Trailing, pending = pending[:n], pending[n:]
+		}
+		if push {
+			entry.Section = &Section{}
+			stack = append(stack, entry.Section)
+			push = false
+		}
+	}
+
+	for token := range Tokens(input) {
+		if token.Error != nil {
+			return nil, tokenError(token)
+		}
+		switch token.Kind {
+		case Indent:
+			push = true
+		case Outdent:
+			pops++
+		case MapKey, ListItem:
+			flush(token.Lno)
+			line := lines[token.Lno-1]
+			start := token.Start - token.Col + 1
+			keyEnd = token.End - start
+			// until a value is found, everything before the comment is the separator
+			rest := line.Text[keyEnd:]
+			separator, _, _ := strings.Cut(rest, ";")
+			separator = strings.TrimRight(separator, " \t")
+			entry = &Entry{
+				Leading:   pending,
+				Indent:    line.Text[:token.Col-1],
+				Key:       string(input[token.Start:token.End]),
+				Separator: separator,
+				Comment:   rest[len(separator):],
+				Newline:   line.Newline,
+			}
+			pending = []Line{}
+			section := stack[len(stack)-1]
+			section.Entries = append(section.Entries, entry)
+			next = token.Lno
+		case Scalar, MultilineHint:
+			line := lines[token.Lno-1]
+			start := token.Start - token.Col + 1
+			entry.Separator = line.Text[keyEnd : token.Start-start]
+			entry.Value = string(input[token.Start:token.End])
+			entry.Comment = line.Text[token.End-start:]
+		case MultilineScalar:
+			entry.Multiline = slices.Clone(lines[next:token.EndLno])
+			next = token.EndLno
+		case NoValue, Comment:
+		}
+	}
+
+	flush(len(lines) + 1)
+	doc.Trailing = pending
+	return doc, nil
+}
+
+// trailingLines returns the number of lines at the start of pending that
+// belong at the end of a section indented by indent. That is all lines up to
+// the last comment that is indented at least as far as the section.
+func trailingLines(pending []Line, indent string) int {
+	n := 0
+	for i, line := range pending {
+		if strings.TrimLeft(line.Text, " \t") == "" {
+			continue
+		}
+		if !strings.HasPrefix(line.Text, indent) {
+			break
+		}
+		n = i + 1
+	}
+	return n
+}
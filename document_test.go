@@ -0,0 +1,180 @@
+package conl_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ConradIrwin/conl-go"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	examples, err := os.ReadFile("testdata/examples.txt")
+	if err != nil {
+		t.Fatalf("Failed to read examples file: %v", err)
+	}
+
+	examplesStr := strings.ReplaceAll(string(examples), "␉", "\t")
+	examplesStr = strings.ReplaceAll(examplesStr, "␊", "\r")
+
+	inputs := []string{
+		"",
+		"\n\n",
+		"; only a comment",
+		"a = b\r\nc = d\re = f",
+		"a = b   \n\n\n; trailing\n\n",
+		"a\n  b\n    c = d ; deep\n    ; end of c\n  ; end of b\n; before e\ne\n",
+		"a = \"quoted\"\nb\n  = b",
+		"list =   ; comment\n\t=   one\n\t=\"two\"\n",
+		"a = \"\"\"sql ; hint\n\n   select *\n\n     from t  \n\n\nb = c",
+		"a = \"\"\"\n  x\n\n",
+	}
+	for _, example := range strings.Split(examplesStr, "\n===\n") {
+		input, _, _ := strings.Cut(example, "\n---\n")
+		inputs = append(inputs, input)
+	}
+
+	for _, input := range inputs {
+		doc, err := conl.ParseDocument([]byte(input))
+		if err != nil {
+			t.Fatalf("Failed to parse: %v\nInput: %#v", err, input)
+		}
+		if output := string(doc.Bytes()); output != input {
+			t.Fatalf("Mismatch:\nExpected: %#v\nGot: %#v", input, output)
+		}
+	}
+}
+
+func TestDocumentStructure(t *testing.T) {
+	input := "; servers\nservers\n  web ; the web server\n    port = \"80\"\n    ; more to come\n\n  = oops\n"
+	if _, err := conl.ParseDocument([]byte(input)); err == nil || err.Error() != "7: unexpected list item" {
+		t.Fatalf("expected error, got %v", err)
+	}
+
+	input = "; servers\nservers\n  web ; the web server\n    port = \"80\"\n    ; more to come\n\n  db\n    script = \"\"\"sh\n      echo hi\n"
+	doc, err := conl.ParseDocument([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servers := doc.Entries[0]
+	if len(servers.Leading) != 1 || servers.Leading[0].Text != "; servers" || servers.KeyContent() != "servers" {
+		t.Fatalf("unexpected servers entry: %#v", servers)
+	}
+	web := servers.Section.Entries[0]
+	if web.Indent != "  " || web.Key != "web" || web.Comment != " ; the web server" || web.Value != "" {
+		t.Fatalf("unexpected web entry: %#v", web)
+	}
+	port := web.Section.Entries[0]
+	if port.Separator != " = " || port.Value != `"80"` || port.Content() != "80" {
+		t.Fatalf("unexpected port entry: %#v", port)
+	}
+	if len(web.Section.Trailing) != 1 || web.Section.Trailing[0].Text != "    ; more to come" {
+		t.Fatalf("unexpected trailing lines: %#v", web.Section.Trailing)
+	}
+	db := servers.Section.Entries[1]
+	if len(db.Leading) != 1 || db.Leading[0].Text != "" {
+		t.Fatalf("unexpected db entry: %#v", db)
+	}
+	script := db.Section.Entries[0]
+	if !script.IsMultiline() || script.Hint() != "sh" || script.Content() != "echo hi" {
+		t.Fatalf("unexpected script entry: %#v", script)
+	}
+}
+
+func FuzzDocument(f *testing.F) {
+	f.Add([]byte("a\n  b = c ; d\n\n  ; e\nf = \"\"\"\n  g\n"))
+	f.Fuzz(func(t *testing.T, input []byte) {
+		doc, err := conl.ParseDocument(input)
+		if err == nil && string(doc.Bytes()) != string(input) {
+			t.Fatalf("Mismatch:\nExpected: %#v\nGot: %#v", string(input), string(doc.Bytes()))
+		}
+	})
+}
+
+func TestDocumentEdit(t *testing.T) {
+	input := `; deploy config
+version = 1.2.3 ; bumped by CI
+
+servers
+    web
+        port = 80 ; http
+    ; the database
+    db
+        port = 5432
+stages
+    = build
+    = test
+script = """sh
+    echo hi
+`
+	doc, err := conl.ParseDocument([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, edit := range []func() error{
+		func() error { return doc.Set("version", "1.2.4") },
+		func() error { return doc.Set("servers/web/port", "8080") },
+		func() error { return doc.Set("servers/web/host", "a = b") },
+		func() error { return doc.Set("servers/cache/port", "6379") },
+		func() error { return doc.Delete("servers/db") },
+		func() error { return doc.InsertListItem("stages", 1, "lint") },
+		func() error { return doc.InsertListItem("stages", 3, "deploy") },
+		func() error { return doc.Set("stages/0", "compile") },
+		func() error { return doc.InsertListItem("tags", 0, " padded") },
+		func() error { return doc.Set("script", "echo hello\necho world") },
+	} {
+		if err := edit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `; deploy config
+version = 1.2.4 ; bumped by CI
+
+servers
+    web
+        port = 8080 ; http
+        host = "a = b"
+    cache
+        port = 6379
+stages
+    = compile
+    = lint
+    = test
+    = deploy
+script = """sh
+    echo hello
+    echo world
+tags
+    = " padded"
+`
+	if output := string(doc.Bytes()); output != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+
+	if err := doc.Set("version/major", "1"); err == nil || err.Error() != "version: not a map or list" {
+		t.Fatalf("expected error, got %v", err)
+	}
+	if err := doc.InsertListItem("servers", 0, "x"); err == nil || err.Error() != "servers: not a list" {
+		t.Fatalf("expected error, got %v", err)
+	}
+	if err := doc.InsertListItem("stages", 5, "x"); err == nil || err.Error() != "stages: index 5 out of range" {
+		t.Fatalf("expected error, got %v", err)
+	}
+	if err := doc.Delete("servers/nope"); err == nil || err.Error() != "servers/nope: not found" {
+		t.Fatalf("expected error, got %v", err)
+	}
+
+	doc, err = conl.ParseDocument([]byte("a = 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Set("b", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if output := string(doc.Bytes()); output != "a = 1\nb = 2\n" {
+		t.Fatalf("got %#v", output)
+	}
+}
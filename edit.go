@@ -0,0 +1,302 @@
+package conl
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// splitPath splits a path like "servers/web/port" into its segments.
+func splitPath(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	segments := strings.Split(path, "/")
+	if slices.Contains(segments, "") {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+	return segments, nil
+}
+
+// index returns the index of the entry in s identified by segment,
+// or -1 if there is none.
+func (s *Section) index(segment string) int {
+	if len(s.Entries) > 0 && s.Entries[0].IsListItem() {
+		i, err := strconv.Atoi(segment)
+		if err != nil || i < 0 || i >= len(s.Entries) {
+			return -1
+		}
+		return i
+	}
+	for i, e := range s.Entries {
+		if e.KeyContent() == segment {
+			return i
+		}
+	}
+	return -1
+}
+
+// Lookup returns the entry identified by path, or nil if there is none.
+//
+// A path is a sequence of map keys or list indexes separated by "/",
+// for example "servers/web/port" or "stages/0/name". Keys that contain
+// a "/" cannot be addressed.
+func (d *Document) Lookup(path string) *Entry {
+	segments, err := splitPath(path)
+	if err != nil || len(segments) == 0 {
+		return nil
+	}
+	section := &d.Section
+	var entry *Entry
+	for _, segment := range segments {
+		if section == nil {
+			return nil
+		}
+		i := section.index(segment)
+		if i < 0 {
+			return nil
+		}
+		entry = section.Entries[i]
+		section = entry.Section
+	}
+	return entry
+}
+
+// Set sets the value at path to the scalar value, quoting it as necessary.
+// Any maps that do not exist along the path are created, and new keys are
+// appended to the end of their map. If the entry already exists its comments
+// are preserved, though any nested map or list is replaced.
+// See [Document.Lookup] for the syntax of path.
+func (d *Document) Set(path, value string) error {
+	segments, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return fmt.Errorf("cannot set the root of the document")
+	}
+	entry, err := d.create(segments)
+	if err != nil {
+		return err
+	}
+	d.setScalar(entry, value)
+	d.fixNewlines()
+	return nil
+}
+
+// Delete removes the entry at path, along with any comments that precede it.
+// See [Document.Lookup] for the syntax of path.
+func (d *Document) Delete(path string) error {
+	segments, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return fmt.Errorf("cannot delete the root of the document")
+	}
+	var parent *Entry
+	section := &d.Section
+	if len(segments) > 1 {
+		parent = d.Lookup(strings.Join(segments[:len(segments)-1], "/"))
+		if parent == nil || parent.Section == nil {
+			return fmt.Errorf("%s: not found", path)
+		}
+		section = parent.Section
+	}
+	i := section.index(segments[len(segments)-1])
+	if i < 0 {
+		return fmt.Errorf("%s: not found", path)
+	}
+
+	// keep any blank lines that separated the entry from the one before
+	blank := []Line{}
+	for _, line := range section.Entries[i].Leading {
+		if strings.TrimLeft(line.Text, " \t") != "" {
+			break
+		}
+		blank = append(blank, line)
+	}
+	section.Entries = slices.Delete(section.Entries, i, i+1)
+	if i < len(section.Entries) {
+		next := section.Entries[i]
+		if len(next.Leading) == 0 || strings.TrimLeft(next.Leading[0].Text, " \t") != "" {
+			next.Leading = append(blank, next.Leading...)
+		}
+	} else {
+		section.Trailing = append(blank, section.Trailing...)
+	}
+	if parent != nil && len(section.Entries) == 0 && len(section.Trailing) == 0 {
+		parent.Section = nil
+	}
+	d.fixNewlines()
+	return nil
+}
+
+// InsertListItem inserts a scalar value into the list at path, so that it
+// has the given index. An index equal to the length of the list appends the item.
+// If there is no value at path, a new list is created.
+// See [Document.Lookup] for the syntax of path.
+func (d *Document) InsertListItem(path string, index int, value string) error {
+	segments, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	section := &d.Section
+	indent := ""
+	if len(segments) > 0 {
+		entry, err := d.create(segments)
+		if err != nil {
+			return err
+		}
+		if entry.Value != "" {
+			return fmt.Errorf("%s: not a list", path)
+		}
+		if entry.Section == nil {
+			entry.Section = &Section{}
+		}
+		section = entry.Section
+		indent = entry.Indent + d.indentUnit()
+	}
+	if len(section.Entries) > 0 && !section.Entries[0].IsListItem() {
+		return fmt.Errorf("%s: not a list", path)
+	}
+	if index < 0 || index > len(section.Entries) {
+		return fmt.Errorf("%s: index %d out of range", path, index)
+	}
+	if len(section.Entries) > 0 {
+		indent = section.Entries[0].Indent
+	}
+
+	entry := &Entry{Indent: indent, Key: "=", Newline: d.newline()}
+	d.setScalar(entry, value)
+	section.Entries = slices.Insert(section.Entries, index, entry)
+	d.fixNewlines()
+	return nil
+}
+
+// create returns the entry identified by segments, creating any
+// entries that are missing.
+func (d *Document) create(segments []string) (*Entry, error) {
+	section := &d.Section
+	var entry *Entry
+	for i, segment := range segments {
+		if entry != nil {
+			if entry.Value != "" {
+				return nil, fmt.Errorf("%s: not a map or list", strings.Join(segments[:i], "/"))
+			}
+			if entry.Section == nil {
+				entry.Section = &Section{}
+			}
+			section = entry.Section
+		}
+
+		if j := section.index(segment); j >= 0 {
+			entry = section.Entries[j]
+			continue
+		}
+		if len(section.Entries) > 0 && section.Entries[0].IsListItem() {
+			return nil, fmt.Errorf("%s: not found", strings.Join(segments[:i+1], "/"))
+		}
+
+		indent := ""
+		if len(section.Entries) > 0 {
+			indent = section.Entries[0].Indent
+		} else if entry != nil {
+			indent = entry.Indent + d.indentUnit()
+		}
+		entry = &Entry{Indent: indent, Key: quoteString(segment), Newline: d.newline()}
+		section.Entries = append(section.Entries, entry)
+	}
+	return entry, nil
+}
+
+// setScalar replaces the value of e with value.
+func (d *Document) setScalar(e *Entry, value string) {
+	if !strings.Contains(e.Separator, "=") && !e.IsListItem() {
+		e.Separator = " ="
+	}
+	if !strings.HasSuffix(e.Separator, " ") {
+		e.Separator += " "
+	}
+	e.Section = nil
+	e.Multiline = nil
+
+	quoted := quoteValue(value, e.Indent+d.indentUnit(), e.Hint())
+	first, rest, found := strings.Cut(quoted, "\n")
+	e.Value = first
+	if found {
+		for _, line := range strings.Split(rest, "\n") {
+			e.Multiline = append(e.Multiline, Line{line, d.newline()})
+		}
+	}
+}
+
+// allLines iterates over every line in the document in order,
+// yielding a pointer to its line ending.
+func (s *Section) allLines(yield func(*string) bool) bool {
+	for _, e := range s.Entries {
+		for i := range e.Leading {
+			if !yield(&e.Leading[i].Newline) {
+				return false
+			}
+		}
+		if !yield(&e.Newline) {
+			return false
+		}
+		for i := range e.Multiline {
+			if !yield(&e.Multiline[i].Newline) {
+				return false
+			}
+		}
+		if e.Section != nil && !e.Section.allLines(yield) {
+			return false
+		}
+	}
+	for i := range s.Trailing {
+		if !yield(&s.Trailing[i].Newline) {
+			return false
+		}
+	}
+	return true
+}
+
+// newline returns the line ending used by the document.
+func (d *Document) newline() string {
+	newline := "\n"
+	d.allLines(func(nl *string) bool {
+		if *nl != "" {
+			newline = *nl
+			return false
+		}
+		return true
+	})
+	return newline
+}
+
+// fixNewlines ensures that every line except the last has a line ending,
+// as edits may have added lines after one that had none.
+func (d *Document) fixNewlines() {
+	newline := d.newline()
+	var last *string
+	d.allLines(func(nl *string) bool {
+		if last != nil && *last == "" {
+			*last = newline
+		}
+		last = nl
+		return true
+	})
+}
+
+// indentUnit returns the indentation used for the first nested section in
+// the document, or two spaces if there is none.
+func (d *Document) indentUnit() string {
+	for _, e := range d.Entries {
+		if e.Section != nil && len(e.Section.Entries) > 0 {
+			if unit := strings.TrimPrefix(e.Section.Entries[0].Indent, e.Indent); unit != "" {
+				return unit
+			}
+		}
+	}
+	return "  "
+}
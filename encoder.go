@@ -0,0 +1,163 @@
+package conl
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// An Encoder writes a CONL document to an output stream from a sequence of tokens.
+//
+// It accepts the tokens produced by [Tokens] (so documents can be filtered or
+// transformed without building a tree), and takes care of indentation and
+// quoting. By default each level of the output is indented by two spaces.
+//
+// Because the encoder has to see the next token to know how a line ends,
+// [Encoder.Flush] must be called after the last token has been written.
+type Encoder struct {
+	w      io.Writer
+	indent string
+	depth  int
+	// cols are the columns of the first entry at each depth, used to decide
+	// whether comments before an [Outdent] belong inside or after the section.
+	cols []int
+	// line is the current line, which has not yet been written.
+	line     string
+	lineKind TokenKind
+	lineLno  int
+	hasValue bool
+	hint     string
+	comment  string
+	block    []string
+	// comments are comments on lines of their own, which are written once
+	// the next entry determines their indentation.
+	comments []Token
+	err      error
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, indent: formatIndent, cols: []int{0}}
+}
+
+// SetIndent sets the string used for each level of indentation, which
+// should consist only of spaces and tabs. The default is two spaces.
+func (e *Encoder) SetIndent(indent string) {
+	e.indent = indent
+}
+
+// WriteToken adds the token to the output.
+//
+// [Comment] tokens are kept on the same line as the preceding key if they
+// share its Lno, and otherwise written on a line of their own. [NoValue]
+// tokens are ignored, and quoting is determined from the content of each
+// token. An error is returned if the token has an error, or if it does
+// not make sense in the current position (for example a [Scalar] that does
+// not follow a [MapKey] or [ListItem]).
+func (e *Encoder) WriteToken(token Token) error {
+	if e.err != nil {
+		return e.err
+	}
+	if token.Error != nil {
+		return tokenError(token)
+	}
+
+	switch token.Kind {
+	case Comment:
+		if e.line != "" && token.Lno != 0 && token.Lno == e.lineLno {
+			e.comment += " ;" + token.Content
+			return nil
+		}
+		e.comments = append(e.comments, token)
+	case Indent:
+		if e.line == "" || e.hasValue {
+			return fmt.Errorf("%d: unexpected %s", token.Lno, token.Kind)
+		}
+		e.writeComments(len(e.comments), e.depth+1)
+		e.depth++
+		e.cols = append(e.cols, 0)
+	case Outdent:
+		if e.depth == 0 {
+			return fmt.Errorf("%d: unexpected %s", token.Lno, token.Kind)
+		}
+		col := e.cols[e.depth]
+		i := 0
+		for i < len(e.comments) && (e.comments[i].Col == 0 || e.comments[i].Col >= col) {
+			i++
+		}
+		e.writeComments(i, e.depth)
+		e.depth--
+		e.cols = e.cols[:e.depth+1]
+	case MapKey, ListItem:
+		e.writeComments(len(e.comments), e.depth)
+		if e.cols[e.depth] == 0 {
+			e.cols[e.depth] = token.Col
+		}
+		e.line = strings.Repeat(e.indent, e.depth) + "="
+		if token.Kind == MapKey {
+			e.line = strings.Repeat(e.indent, e.depth) + quoteString(token.Content)
+		}
+		e.lineKind, e.lineLno, e.hasValue, e.hint = token.Kind, token.Lno, false, ""
+	case NoValue:
+	case MultilineHint:
+		if e.line == "" || e.hasValue {
+			return fmt.Errorf("%d: unexpected %s", token.Lno, token.Kind)
+		}
+		e.hint = token.Content
+	case Scalar, MultilineScalar:
+		if e.line == "" || e.hasValue {
+			return fmt.Errorf("%d: unexpected %s", token.Lno, token.Kind)
+		}
+		e.hasValue = true
+		if e.lineKind == MapKey {
+			e.line += " = "
+		} else {
+			e.line += " "
+		}
+		if token.Kind == Scalar || !canBeMultiline(token.Content) {
+			e.line += quoteString(token.Content)
+			return nil
+		}
+		e.line += `"""` + e.hint
+		indent := strings.Repeat(e.indent, e.depth+1)
+		for _, line := range strings.Split(token.Content, "\n") {
+			if line != "" {
+				line = indent + line
+			}
+			e.block = append(e.block, line)
+		}
+	default:
+		return fmt.Errorf("%d: unexpected %s", token.Lno, token.Kind)
+	}
+	return e.err
+}
+
+// Flush writes any buffered output to the underlying writer.
+func (e *Encoder) Flush() error {
+	if e.err == nil {
+		e.writeComments(len(e.comments), e.depth)
+	}
+	return e.err
+}
+
+// writeComments writes the current line, followed by the first n pending
+// comments at the given depth.
+func (e *Encoder) writeComments(n int, depth int) {
+	if e.line != "" {
+		e.write(e.line + e.comment)
+		for _, line := range e.block {
+			e.write(line)
+		}
+		e.line, e.comment, e.block = "", "", nil
+	}
+	for _, c := range e.comments[:n] {
+		e.write(strings.Repeat(e.indent, depth) + ";" + c.Content)
+	}
+	e.comments = e.comments[n:]
+}
+
+func (e *Encoder) write(line string) {
+	if e.err == nil {
+		_, e.err = io.WriteString(e.w, line+"\n")
+	}
+}
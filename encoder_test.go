@@ -0,0 +1,100 @@
+package conl_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ConradIrwin/conl-go"
+)
+
+func encode(t *testing.T, tokens []conl.Token) string {
+	t.Helper()
+	output := &strings.Builder{}
+	enc := conl.NewEncoder(output)
+	for _, token := range tokens {
+		if err := enc.WriteToken(token); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return output.String()
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	examples, err := os.ReadFile("testdata/examples.txt")
+	if err != nil {
+		t.Fatalf("Failed to read examples.txt: %v", err)
+	}
+	examplesStr := strings.ReplaceAll(string(examples), "␉", "\t")
+	examplesStr = strings.ReplaceAll(examplesStr, "␊", "\r")
+
+	for _, example := range strings.Split(examplesStr, "\n===\n") {
+		input, _, _ := strings.Cut(example, "\n---\n")
+		tokens := []conl.Token{}
+		for token := range conl.Tokens([]byte(input)) {
+			tokens = append(tokens, token)
+		}
+		output := encode(t, tokens)
+
+		expected, err := toJSON([]byte(input))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		actual, err := toJSON([]byte(output))
+		if err != nil || actual != expected {
+			t.Errorf("Encoding changed meaning:\nInput: %s\nOutput: %s\nError: %v", input, output, err)
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	input := "; header\nserver ; the server\n\t; address\n\thost = \"a = b\"\n\tport = 80 ; http\n\t; end of server\n; footer\nscript = \"\"\"sh ; shell\n    echo hi\n\n      there\nlist\n  = one\n  =\n"
+
+	tokens := []conl.Token{}
+	for token := range conl.Tokens([]byte(input)) {
+		if token.Kind == conl.MapKey && token.Content == "host" {
+			token.Content = "hostname"
+		}
+		tokens = append(tokens, token)
+	}
+	expected := `; header
+server ; the server
+  ; address
+  hostname = "a = b"
+  port = 80 ; http
+  ; end of server
+; footer
+script = """sh ; shell
+  echo hi
+
+    there
+list
+  = one
+  =
+`
+	if output := encode(t, tokens); output != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+
+	stripped := []conl.Token{}
+	for _, token := range tokens {
+		if token.Kind != conl.Comment {
+			stripped = append(stripped, token)
+		}
+	}
+	expected = "server\n  hostname = \"a = b\"\n  port = 80\nscript = \"\"\"sh\n  echo hi\n\n    there\nlist\n  = one\n  =\n"
+	if output := encode(t, stripped); output != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+
+	enc := conl.NewEncoder(&strings.Builder{})
+	if err := enc.WriteToken(conl.Token{Lno: 1, Kind: conl.Scalar, Content: "a"}); err == nil || err.Error() != "1: unexpected Value" {
+		t.Fatalf("expected error, got %v", err)
+	}
+	if err := enc.WriteToken(conl.Token{Lno: 1, Kind: conl.Outdent}); err == nil || err.Error() != "1: unexpected Outdent" {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package conl
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// SyntaxErrorKind identifies the kind of a [SyntaxError].
+type SyntaxErrorKind int
+
+const (
+	// InvalidUTF8 is reported for content that is not valid UTF-8.
+	InvalidUTF8 SyntaxErrorKind = iota + 1
+	// UnclosedQuotes is reported for a quoted literal with no closing quote.
+	UnclosedQuotes
+	// CharactersAfterQuotes is reported when a quoted literal is followed by
+	// something other than whitespace, "=" (for keys) or a comment.
+	CharactersAfterQuotes
+	// InvalidEscape is reported for an unknown escape sequence in a quoted literal.
+	InvalidEscape
+	// MissingMultilineValue is reported when `"""` is not followed by an
+	// indented block.
+	MissingMultilineValue
+	// UnexpectedIndent is reported for a line that is indented further than the
+	// one before it, where the line before already had a value.
+	UnexpectedIndent
+	// UnexpectedListItem is reported for a list item in a map.
+	UnexpectedListItem
+	// UnexpectedMapKey is reported for a map key in a list.
+	UnexpectedMapKey
+)
+
+func (k SyntaxErrorKind) String() string {
+	switch k {
+	case InvalidUTF8:
+		return "InvalidUTF8"
+	case UnclosedQuotes:
+		return "UnclosedQuotes"
+	case CharactersAfterQuotes:
+		return "CharactersAfterQuotes"
+	case InvalidEscape:
+		return "InvalidEscape"
+	case MissingMultilineValue:
+		return "MissingMultilineValue"
+	case UnexpectedIndent:
+		return "UnexpectedIndent"
+	case UnexpectedListItem:
+		return "UnexpectedListItem"
+	case UnexpectedMapKey:
+		return "UnexpectedMapKey"
+	}
+	return fmt.Sprintf("SyntaxErrorKind(%d)", int(k))
+}
+
+// A SyntaxError describes invalid CONL syntax. It is reported in Token.Error
+// by [Tokens]. The errors returned by [Unmarshal], [ParseDocument] and [Check]
+// wrap it, adding the line number.
+type SyntaxError struct {
+	// Lno and Col are the (1-based) line number and byte column of the
+	// token that contains the error.
+	Lno, Col int
+	Kind     SyntaxErrorKind
+	// Msg is the error message without position information.
+	Msg string
+}
+
+// Error returns the error message, without position information.
+func (e *SyntaxError) Error() string {
+	return e.Msg
+}
+
+// syntaxError creates a [SyntaxError], which is positioned when it is
+// attached to a token.
+func syntaxError(kind SyntaxErrorKind, format string, args ...any) error {
+	return &SyntaxError{Kind: kind, Msg: fmt.Sprintf(format, args...)}
+}
+
+// positionError sets the position of err if it is a [SyntaxError].
+func positionError(err error, lno, col int) error {
+	if se, ok := err.(*SyntaxError); ok {
+		se.Lno, se.Col = lno, col
+	}
+	return err
+}
+
+// tokenError returns the error in token, prefixed by its line number.
+func tokenError(token Token) error {
+	return fmt.Errorf("%d: %w", token.Lno, token.Error)
+}
+
+// Check returns every syntax error in the document, in the order they occur.
+// Each error wraps a [*SyntaxError] and is prefixed by its line number. It
+// returns nil if the document is valid.
+func Check(data []byte) []error {
+	var errs []error
+	for token := range Tokens(data) {
+		if token.Error != nil {
+			errs = append(errs, tokenError(token))
+		}
+	}
+	return errs
+}
+
+// An UnknownFieldError is returned by [Unmarshal] when a key in the document
+// does not match any field of the struct being decoded.
+type UnknownFieldError struct {
+	// Lno is the line number of the key.
+	Lno int
+	// Path is the path to the key, for example "servers[2].tyme".
+	Path string
+	// Field is the key that was not recognized.
+	Field string
+}
+
+// Error returns a message including the line number and path,
+// for example "9: unknown field servers[2].tyme".
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("%d: unknown field %s", e.Lno, e.Path)
+}
+
+// A MissingFieldError is returned by [Unmarshal] when a field with the
+// "required" option is not present in the document, or is present with no
+// value.
+type MissingFieldError struct {
+	// Lno is the line number of the key that contains the field (or 1 at the
+	// top level), or of the field's own key if it has no value.
+	Lno int
+	// Path is the path to the missing field, for example "servers[2].url".
+	Path string
+	// Field is the name of the missing field.
+	Field string
+}
+
+// Error returns a message including the line number and path,
+// for example "7: missing required field servers[2].host".
+func (e *MissingFieldError) Error() string {
+	return fmt.Sprintf("%d: missing required field %s", e.Lno, e.Path)
+}
+
+// A TypeError is returned by [Unmarshal] when a value in the document cannot
+// be stored in the corresponding Go value, for example when a scalar is not a
+// valid number, or a map is found where a list is expected.
+type TypeError struct {
+	// Lno is the line number of the value.
+	Lno int
+	// Path is the path to the value, for example "servers[2].port".
+	Path string
+	// Type is the Go type of the value.
+	Type reflect.Type
+	// Err describes the problem. It may be an error from the [strconv]
+	// package, or from an [encoding.TextUnmarshaler].
+	Err error
+}
+
+// Error returns a message including the line number and path (if any),
+// for example "8: servers[2].port: strconv.ParseUint: parsing "http": invalid syntax".
+func (e *TypeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%d: %v", e.Lno, e.Err)
+	}
+	return fmt.Sprintf("%d: %s: %v", e.Lno, e.Path, e.Err)
+}
+
+func (e *TypeError) Unwrap() error {
+	return e.Err
+}
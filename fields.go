@@ -0,0 +1,252 @@
+package conl
+
+import (
+	"cmp"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// A field is a struct field that can be marshalled or unmarshalled,
+// either declared directly on the struct or promoted from an embedded struct.
+type field struct {
+	// name is the name of the field in CONL, and tagged is true if it
+	// came from a tag.
+	name   string
+	tagged bool
+	// omitEmpty, required and remaining are set by the options of the same
+	// name, and hint is the value of a "hint=" option.
+	omitEmpty, required, remaining bool
+	hint                           string
+	// def is the default value from a "default=" option, which extends to
+	// the end of the tag so that it can contain commas.
+	def        string
+	hasDefault bool
+	// doc is the content of the doc tag, written as a comment when marshalling.
+	doc string
+	// index is the path to the field for [reflect.Value.FieldByIndex].
+	index []int
+	sf    reflect.StructField
+}
+
+// typeFields returns the fields of the struct type t in declaration order.
+//
+// It follows the rules of encoding/json: the fields of embedded structs
+// (or pointers to structs) without a name in their tag are promoted, including
+// the exported fields of unexported embedded structs (but not of unexported
+// embedded pointers). If several fields have the same name, the shallowest
+// one wins; and if there are several at the same depth, a tagged field wins.
+// If there is still more than one, none of them are included.
+func typeFields(t reflect.Type) []field {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []field
+	next := []embedded{{typ: t}}
+	visited := map[reflect.Type]bool{}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		count := map[reflect.Type]int{}
+		for _, e := range current {
+			count[e.typ]++
+		}
+
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i := range e.typ.NumField() {
+				sf := e.typ.Field(i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if !sf.IsExported() && !(sf.Anonymous && sf.Type.Kind() == reflect.Struct) {
+					// the exported fields of an unexported embedded struct can
+					// be promoted, but an unexported embedded pointer cannot
+					// be allocated.
+					continue
+				}
+
+				tag, ok := sf.Tag.Lookup("conl")
+				if !ok {
+					tag = sf.Tag.Get("json")
+				}
+				if tag == "-" {
+					continue
+				}
+				name, options, _ := strings.Cut(tag, ",")
+				index := append(slices.Clone(e.index), i)
+
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, embedded{typ: ft, index: index})
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+
+				f := field{name: name, tagged: name != "", doc: sf.Tag.Get("doc"), index: index, sf: sf}
+				if before, def, ok := strings.Cut(","+options, ",default="); ok {
+					options, f.def, f.hasDefault = strings.TrimPrefix(before, ","), def, true
+				}
+				for option := range strings.SplitSeq(options, ",") {
+					switch option {
+					case "omitempty":
+						f.omitEmpty = true
+					case "required":
+						f.required = true
+					case "remaining":
+						f.remaining = true
+					default:
+						if hint, ok := strings.CutPrefix(option, "hint="); ok {
+							f.hint = hint
+						}
+					}
+				}
+				if f.name == "" {
+					f.name = sf.Name
+				}
+				fields = append(fields, f)
+				if count[e.typ] > 1 {
+					// the same struct is embedded twice at this depth,
+					// so its fields conflict with each other.
+					fields = append(fields, f)
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(fields, func(a, b field) int {
+		return cmp.Or(
+			strings.Compare(a.name, b.name),
+			cmp.Compare(len(a.index), len(b.index)),
+			compareBool(b.tagged, a.tagged),
+		)
+	})
+	result := []field{}
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if dominant, ok := dominantField(fields[i:j]); ok {
+			result = append(result, dominant)
+		}
+		i = j
+	}
+	slices.SortFunc(result, func(a, b field) int {
+		return slices.Compare(a.index, b.index)
+	})
+	return result
+}
+
+// structFields are the fields of a struct type, along with the lookups
+// needed to marshal and unmarshal it with a given [NamingPolicy].
+type structFields struct {
+	list []field
+	// keys are the CONL keys written for each field in list.
+	keys []string
+	// byName maps each key that matches a field to its index in list.
+	byName map[string]int
+	// remaining is the index of the field with the "remaining" option, or -1.
+	remaining int
+}
+
+type fieldCacheKey struct {
+	t      reflect.Type
+	naming NamingPolicy
+}
+
+// fieldCache maps a fieldCacheKey to its *structFields.
+var fieldCache sync.Map
+
+// cachedFields returns the fields of the struct type t, which are computed
+// once for each type and naming policy.
+func cachedFields(t reflect.Type, naming NamingPolicy) *structFields {
+	key := fieldCacheKey{t, naming}
+	if fields, ok := fieldCache.Load(key); ok {
+		return fields.(*structFields)
+	}
+
+	fields := &structFields{list: typeFields(t), byName: map[string]int{}, remaining: -1}
+	for i, f := range fields.list {
+		key := f.name
+		if !f.tagged {
+			key = naming.fieldName(f.name)
+		}
+		fields.keys = append(fields.keys, key)
+		if f.remaining {
+			fields.remaining = i
+			continue
+		}
+		fields.byName[f.name] = i
+	}
+	for i, f := range fields.list {
+		name := naming.fieldName(f.name)
+		if naming == DefaultNaming {
+			name = toSnakeCase(f.name)
+		}
+		if _, ok := fields.byName[name]; !ok && !f.tagged && i != fields.remaining {
+			fields.byName[name] = i
+		}
+	}
+	actual, _ := fieldCache.LoadOrStore(key, fields)
+	return actual.(*structFields)
+}
+
+// dominantField returns the field that wins among fields with the same name,
+// which have been sorted by depth and then by whether they are tagged.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// fieldByIndex returns the field at index within v, which must be addressable.
+// Any nil embedded pointers along the way are allocated.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// fieldValue returns the field at index within v, or false if it is
+// within a nil embedded pointer.
+func fieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
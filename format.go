@@ -0,0 +1,109 @@
+package conl
+
+import (
+	"strings"
+)
+
+// formatIndent is the unit of indentation used by [Format].
+const formatIndent = "  "
+
+// Format returns the canonical formatting of a CONL document.
+//
+// Each level of the document is indented by two spaces, and keys and values
+// are only quoted when necessary. Multiline scalars are re-indented, though
+// their content is otherwise preserved. Comments stay attached to the line
+// they precede or follow; trailing whitespace, blank lines at the start of a
+// section, and runs of more than one blank line are removed. Lines are
+// terminated with "\n".
+//
+// An error is returned if the input is not valid CONL.
+func Format(input []byte) ([]byte, error) {
+	doc, err := ParseDocument(input)
+	if err != nil {
+		return nil, err
+	}
+	doc.format("")
+	doc.Trailing = trimBlankLines(doc.Trailing)
+	return doc.Bytes(), nil
+}
+
+func (s *Section) format(indent string) {
+	for i, e := range s.Entries {
+		e.Leading = formatLines(e.Leading, indent, i == 0)
+		e.format(indent)
+	}
+	s.Trailing = formatLines(s.Trailing, indent, len(s.Entries) == 0)
+}
+
+func (e *Entry) format(indent string) {
+	content := e.Content()
+	e.Indent = indent
+	e.Newline = "\n"
+	if !e.IsListItem() {
+		e.Key = quoteString(e.KeyContent())
+	}
+
+	switch {
+	case e.IsMultiline():
+		e.Multiline = nil
+		if !canBeMultiline(content) {
+			e.Value = quoteString(content)
+			break
+		}
+		e.Value = `"""` + e.Hint()
+		for _, line := range strings.Split(content, "\n") {
+			if line != "" {
+				line = indent + formatIndent + line
+			}
+			e.Multiline = append(e.Multiline, Line{line, "\n"})
+		}
+	case e.Value != "":
+		e.Value = quoteString(content)
+	}
+
+	if e.Value == "" {
+		e.Separator = ""
+	} else if e.IsListItem() {
+		e.Separator = " "
+	} else {
+		e.Separator = " = "
+	}
+
+	if comment := strings.TrimSpace(e.Comment); comment != "" {
+		e.Comment = " " + comment
+	} else {
+		e.Comment = ""
+	}
+
+	if e.Section != nil {
+		e.Section.format(indent + formatIndent)
+	}
+}
+
+// formatLines re-indents comments, removes trailing whitespace and
+// collapses runs of blank lines. If start is true, leading blank lines
+// are removed entirely.
+func formatLines(lines []Line, indent string, start bool) []Line {
+	result := []Line{}
+	blank := start
+	for _, line := range lines {
+		text := strings.TrimSpace(line.Text)
+		if text == "" {
+			if !blank {
+				result = append(result, Line{"", "\n"})
+			}
+			blank = true
+			continue
+		}
+		result = append(result, Line{indent + text, "\n"})
+		blank = false
+	}
+	return result
+}
+
+func trimBlankLines(lines []Line) []Line {
+	for len(lines) > 0 && lines[len(lines)-1].Text == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,66 @@
+package conl_test
+
+import (
+	"testing"
+
+	"github.com/ConradIrwin/conl-go"
+)
+
+func TestFormat(t *testing.T) {
+	input := "\n\n; header   \r\nname = \"example\"   \n\"port\"=80;http\n\n\n\nservers\n\n\t\tweb\n\t\t\t\"host\" = \"a = b\"\n\t\t\t; end of web  \n\t\tdb =  ; no value\nlist =\n  =\"one\"\n  =   two\nscript = \"\"\"sh ; shell\n        echo hi\n\n          indented  \n\n\n\n"
+
+	expected := `; header
+name = example
+port = 80 ;http
+
+servers
+  web
+    host = "a = b"
+    ; end of web
+  db ; no value
+list
+  = one
+  = two
+script = """sh ; shell
+  echo hi
+
+    indented
+`
+
+	output, err := conl.Format([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, string(output))
+	}
+
+	if _, err := conl.Format([]byte("a = \"b")); err == nil || err.Error() != "1: unclosed quotes" {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func FuzzFormat(f *testing.F) {
+	f.Add([]byte("a\n    b = \"c\" ; d\n\n\n    ; e\nf = \"\"\"\n      g\n\n        h\n"))
+	f.Add([]byte("= \"\"\"\n \n 00"))
+	f.Add([]byte("0\n = \"\"\"\n  00"))
+	f.Add([]byte("0=="))
+	f.Fuzz(func(t *testing.T, input []byte) {
+		output, err := conl.Format(input)
+		if err != nil {
+			return
+		}
+		expected, err := toJSON(input)
+		if err != nil {
+			t.Fatalf("formatted invalid input: %#v", string(input))
+		}
+		actual, err := toJSON(output)
+		if err != nil || actual != expected {
+			t.Fatalf("formatting changed meaning of %#v\ngot: %#v", string(input), string(output))
+		}
+		again, err := conl.Format(output)
+		if err != nil || string(again) != string(output) {
+			t.Fatalf("formatting is not idempotent for %#v\nfirst: %#v\nsecond: %#v", string(input), string(output), string(again))
+		}
+	})
+}
@@ -3,12 +3,14 @@ package conl
 import (
 	"encoding"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"iter"
 	"reflect"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -16,11 +18,19 @@ func requiresQuote(r rune) bool {
 	return r <= 0x1f || r == ';' || r == '='
 }
 
+func needsQuote(s string) bool {
+	return strings.ContainsFunc(s, requiresQuote) || len(s) == 0 || s[0] == '"' || s[0] == ' ' || s[len(s)-1] == ' '
+}
+
 func quoteString(s string) string {
-	if !(strings.ContainsFunc(s, requiresQuote) || len(s) == 0 || s[0] == '"' || s[0] == ' ' || s[len(s)-1] == ' ') {
+	if !needsQuote(s) {
 		return s
 	}
+	return quote(s)
+}
 
+// quote returns s as a quoted literal.
+func quote(s string) string {
 	r := "\""
 	for _, c := range s {
 		switch {
@@ -45,8 +55,13 @@ func quoteString(s string) string {
 	return r
 }
 
+// canBeMultiline returns true if s can be represented as a multiline scalar.
+func canBeMultiline(s string) bool {
+	return !(s == "" || strings.Contains(s, "\r") || unicode.IsSpace(rune(s[0])) || unicode.IsSpace(rune(s[len(s)-1])))
+}
+
 func quoteValue(s string, indent, hint string) string {
-	if s == "" || strings.Contains(s, "\r") || unicode.IsSpace(rune(s[0])) || unicode.IsSpace(rune(s[len(s)-1])) {
+	if !canBeMultiline(s) {
 		return quoteString(s)
 	}
 	if hint == "" && !strings.Contains(s, "\n") {
@@ -57,27 +72,43 @@ func quoteValue(s string, indent, hint string) string {
 }
 
 func marshalKey(v any) (string, error) {
+	text, err := keyText(v, true)
+	if err != nil {
+		return "", err
+	}
+	return quoteString(text), nil
+}
+
+// keyText returns the content of a map key. If composite is true, structs and
+// arrays are written as a list of components separated by ", ".
+func keyText(v any, composite bool) (string, error) {
 	if m, ok := v.(encoding.TextMarshaler); ok {
-		if text, err := m.MarshalText(); err == nil {
-			return quoteString(string(text)), nil
-		} else {
+		text, err := m.MarshalText()
+		if err != nil {
 			return "", err
 		}
+		return string(text), nil
 	}
 
 	val := reflect.ValueOf(v)
-	val.IsValid()
 	switch val.Kind() {
 	case reflect.Pointer, reflect.Interface:
 		if !val.IsNil() {
-			return marshalKey(val.Elem().Interface())
+			return keyText(val.Elem().Interface(), composite)
 		}
 	case reflect.Array:
 		if val.Type().Elem().Kind() == reflect.Uint8 {
 			return base64.RawStdEncoding.EncodeToString(val.Bytes()), nil
 		}
+		if composite {
+			return compositeKey(val)
+		}
+	case reflect.Struct:
+		if composite {
+			return compositeKey(val)
+		}
 	case reflect.String:
-		return quoteString(val.String()), nil
+		return val.String(), nil
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
 		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.Bool:
@@ -86,12 +117,148 @@ func marshalKey(v any) (string, error) {
 	return "", fmt.Errorf("unsupported map key type: %s", val.Type())
 }
 
-func marshalValue(v any, indent, hint string) (string, bool, error) {
+// compositeKey returns the content of a struct or array map key. Components
+// that contain a comma, or that would need quoting as a key, are quoted.
+func compositeKey(val reflect.Value) (string, error) {
+	parts := []string{}
+	for _, c := range keyComponents(val) {
+		if !c.IsValid() {
+			return "", fmt.Errorf("unsupported map key type: %s", val.Type())
+		}
+		text, err := keyText(c.Interface(), false)
+		if err != nil {
+			return "", err
+		}
+		if needsQuote(text) || strings.Contains(text, ",") {
+			text = quote(text)
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// keyComponents returns the fields of a struct or the elements of an array.
+// If val is settable any nil embedded pointers are allocated, otherwise
+// fields within them are returned as the zero Value.
+func keyComponents(val reflect.Value) []reflect.Value {
+	components := []reflect.Value{}
+	if val.Kind() == reflect.Array {
+		for i := range val.Len() {
+			components = append(components, val.Index(i))
+		}
+		return components
+	}
+	for _, f := range cachedFields(val.Type(), DefaultNaming).list {
+		if val.CanSet() {
+			components = append(components, fieldByIndex(val, f.index))
+		} else {
+			fv, _ := fieldValue(val, f.index)
+			components = append(components, fv)
+		}
+	}
+	return components
+}
+
+// isCompositeKey returns true if map keys of type t are written as a list
+// of components.
+func isCompositeKey(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return false
+	}
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Array && t.Elem().Kind() != reflect.Uint8
+}
+
+// splitKey splits the content of a composite map key into its components.
+func splitKey(s string) ([]string, error) {
+	parts := []string{}
+	if strings.TrimSpace(s) == "" {
+		return parts, nil
+	}
+	for {
+		s = strings.TrimLeft(s, " \t")
+		part := ""
+		if literal := literalRegex.FindString(s); literal != "" {
+			content, err := decodeLiteral(literal)
+			if err != nil {
+				return nil, err
+			}
+			part, s = content, strings.TrimLeft(s[len(literal):], " \t")
+			if s != "" && s[0] != ',' {
+				return nil, fmt.Errorf("characters after quotes")
+			}
+		} else {
+			end := strings.Index(s, ",")
+			if end < 0 {
+				end = len(s)
+			}
+			part, s = strings.TrimRight(s[:end], " \t"), s[end:]
+		}
+		parts = append(parts, part)
+		if s == "" {
+			return parts, nil
+		}
+		s = s[1:]
+	}
+}
+
+// MarshalOptions controls the output of [MarshalWithOptions].
+// The zero value produces the same output as [Marshal].
+type MarshalOptions struct {
+	// Indent is the string used for each level of indentation, which must
+	// consist only of spaces and tabs. It defaults to two spaces.
+	Indent string
+	// CompareKeys, if set, is used to sort the keys of Go maps (as they
+	// appear in the output). By default keys are sorted alphabetically.
+	// Struct fields are always written in the order they are declared,
+	// and [OrderedMap] keys in the order they were added.
+	CompareKeys func(a, b string) int
+	// OmitEmptyComment omits the "; empty" comment that is otherwise
+	// written for empty maps, lists and structs.
+	OmitEmptyComment bool
+	// BlankLines adds a blank line around each top-level entry that
+	// spans multiple lines.
+	BlankLines bool
+	// Naming determines the keys written for struct fields that do not
+	// have a name in their tag.
+	Naming NamingPolicy
+}
+
+// encodeState holds the options for a single call to [MarshalWithOptions].
+type encodeState struct {
+	opts MarshalOptions
+}
+
+func (e *encodeState) marshalValue(v any, indent, hint string) (string, bool, error) {
+	if v == nil {
+		return " ; nil", false, nil
+	}
+	if m, ok := v.(Marshaler); ok {
+		return e.marshalTokens(m, indent+e.opts.Indent, hint)
+	}
 	val := reflect.ValueOf(v)
 
+	switch cv := v.(type) {
+	case Value:
+		return e.marshalConlValue(&cv, indent)
+	case *Value:
+		if cv != nil {
+			return e.marshalConlValue(cv, indent)
+		}
+	case Multiline:
+		return e.marshalMultiline(&cv, indent, hint)
+	case *Multiline:
+		if cv != nil {
+			return e.marshalMultiline(cv, indent, hint)
+		}
+	case positionedValue:
+		if val.Kind() != reflect.Pointer || !val.IsNil() {
+			return e.marshalValue(cv.value(), indent, hint)
+		}
+	}
+
 	if m, ok := v.(encoding.TextMarshaler); ok {
 		if text, err := m.MarshalText(); err == nil {
-			return quoteValue(string(text), indent+"  ", hint), true, nil
+			return quoteValue(string(text), indent+e.opts.Indent, hint), true, nil
 		} else {
 			return "", false, err
 		}
@@ -102,7 +269,7 @@ func marshalValue(v any, indent, hint string) (string, bool, error) {
 		if val.IsNil() {
 			return " ; nil", false, nil
 		}
-		return marshalValue(val.Elem().Interface(), indent, hint)
+		return e.marshalValue(val.Elem().Interface(), indent, hint)
 	case reflect.Slice, reflect.Array:
 		if val.Type().Elem().Kind() == reflect.Uint8 {
 			bytes := base64.RawStdEncoding.EncodeToString(val.Bytes())
@@ -114,17 +281,17 @@ func marshalValue(v any, indent, hint string) (string, bool, error) {
 					wrappedBytes += bytes[i:]
 				}
 			}
-			return quoteValue(string(wrappedBytes), indent+"  ", hint), true, nil
+			return quoteValue(string(wrappedBytes), indent+e.opts.Indent, hint), true, nil
 		}
 		fallthrough
 	case reflect.Map, reflect.Struct:
-		section, err := marshalSection(v, indent+"  ")
-		if err != nil {
+		section, err := e.marshalSection(v, indent+e.opts.Indent)
+		if err != nil || section == "" {
 			return "", false, err
 		}
 		return "\n" + section, false, nil
 	case reflect.String:
-		return quoteValue(val.String(), indent+"  ", hint), true, nil
+		return quoteValue(val.String(), indent+e.opts.Indent, hint), true, nil
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
 		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.Bool:
@@ -134,106 +301,295 @@ func marshalValue(v any, indent, hint string) (string, bool, error) {
 	}
 }
 
-func marshalSection(v any, indent string) (string, error) {
+func (e *encodeState) marshalSection(v any, indent string) (string, error) {
+	switch cv := v.(type) {
+	case Value:
+		return e.marshalConlSection(&cv, indent)
+	case *Value:
+		if cv != nil {
+			return e.marshalConlSection(cv, indent)
+		}
+	case Multiline, *Multiline:
+		return "", fmt.Errorf("unsupported type: %T", v)
+	case positionedValue:
+		if val := reflect.ValueOf(v); val.Kind() != reflect.Pointer || !val.IsNil() {
+			return e.marshalSection(cv.value(), indent)
+		}
+	case Marshaler:
+		value, eq, err := e.marshalTokens(cv, indent, "")
+		if err != nil {
+			return "", err
+		}
+		if eq || value == "" {
+			return "", fmt.Errorf("%T: MarshalCONL must return a map or a list", v)
+		}
+		return strings.TrimPrefix(value, "\n"), nil
+	case orderedEntries:
+		strs := []string{}
+		for key, value := range cv.entries() {
+			k, err := marshalKey(key)
+			if err != nil {
+				return "", err
+			}
+			str, err := e.marshalEntry(k, value, indent, "")
+			if err != nil {
+				return "", err
+			}
+			strs = append(strs, str)
+		}
+		return e.joinSection(strs, indent), nil
+	}
+
 	val := reflect.ValueOf(v)
 	switch val.Kind() {
 	case reflect.Pointer, reflect.Interface:
-		return marshalSection(val.Elem().Interface(), indent)
+		return e.marshalSection(val.Elem().Interface(), indent)
 	case reflect.Struct:
 		strs := []string{}
-		for i := range val.Type().NumField() {
-			field := val.Type().Field(i)
-			if !field.IsExported() {
-				continue
-			}
-			tag, ok := field.Tag.Lookup("conl")
+		fields := cachedFields(val.Type(), e.opts.Naming)
+		for i, field := range fields.list {
+			fv, ok := fieldValue(val, field.index)
 			if !ok {
-				tag, _ = field.Tag.Lookup("json")
-			}
-			if tag == "-" {
 				continue
 			}
-			name, options, found := strings.Cut(tag, ",")
-			if !found {
-				name = tag
-			}
-			if name == "" {
-				name = field.Name
-			}
-			fv := val.Field(i)
-			if strings.Contains(options, "omitempty") {
-				if !fv.IsValid() || fv.IsZero() {
-					continue
+			if i == fields.remaining {
+				entries, err := e.remainingEntries(fv, indent)
+				if err != nil {
+					return "", err
 				}
+				strs = append(strs, entries...)
+				continue
 			}
-			hint := ""
-			if _, tag, ok := strings.Cut(options, "hint="); ok {
-				hint, _, _ = strings.Cut(tag, ",")
+			if field.omitEmpty && (!fv.IsValid() || fv.IsZero()) {
+				continue
 			}
-			v, eq, err := marshalValue(fv.Interface(), indent, hint)
+			str, err := e.marshalEntry(quoteString(fields.keys[i]), fv.Interface(), indent, field.hint)
 			if err != nil {
 				return "", err
 			}
-			if eq {
-				strs = append(strs, quoteString(name)+" = "+v)
-			} else {
-				strs = append(strs, quoteString(name)+v)
+			if field.doc != "" {
+				str = docComment(field.doc, indent) + str
 			}
+			strs = append(strs, str)
 		}
-
-		if len(strs) == 0 {
-			return indent + "; empty", nil
-		}
-		return indent + strings.Join(strs, "\n"+indent), nil
+		return e.joinSection(strs, indent), nil
 	case reflect.Map:
-		strs := []string{}
-		for _, key := range val.MapKeys() {
-			k, err := marshalKey(key.Interface())
-			if err != nil {
-				return "", err
-			}
-			v, eq, err := marshalValue(val.MapIndex(key).Interface(), indent, "")
-			if err != nil {
-				return "", err
-			}
-			if eq {
-				strs = append(strs, k+" = "+v)
-			} else {
-				strs = append(strs, k+v)
-			}
-		}
-		if len(strs) == 0 {
-			return indent + "; empty", nil
+		strs, err := e.mapEntries(val, indent)
+		if err != nil {
+			return "", err
 		}
-		slices.Sort(strs)
-		return indent + strings.Join(strs, "\n"+indent), nil
+		return e.joinSection(strs, indent), nil
 	case reflect.Slice, reflect.Array:
 		strs := []string{}
 		for i := range val.Len() {
-			v, _, err := marshalValue(val.Index(i).Interface(), indent, "")
+			str, err := e.marshalEntry("=", val.Index(i).Interface(), indent, "")
 			if err != nil {
 				return "", err
 			}
-			strs = append(strs, v)
-		}
-		if len(strs) == 0 {
-			return indent + "; empty", nil
+			strs = append(strs, str)
 		}
-		return indent + "= " + strings.Join(strs, "\n"+indent+"= "), nil
+		return e.joinSection(strs, indent), nil
 	default:
 		return "", fmt.Errorf("unsupported type: %s", val.Kind())
 	}
 }
 
+// mapEntries returns the entries of a map in sorted order, without joining
+// them into a section.
+func (e *encodeState) mapEntries(val reflect.Value, indent string) ([]string, error) {
+	keys := []string{}
+	strs := []string{}
+	for _, key := range val.MapKeys() {
+		k, err := marshalKey(key.Interface())
+		if err != nil {
+			return nil, err
+		}
+		str, err := e.marshalEntry(k, val.MapIndex(key).Interface(), indent, "")
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+		strs = append(strs, str)
+	}
+	if e.opts.CompareKeys == nil {
+		slices.Sort(strs)
+	} else {
+		order := make([]int, len(keys))
+		for i := range order {
+			order[i] = i
+		}
+		slices.SortStableFunc(order, func(a, b int) int { return e.opts.CompareKeys(keys[a], keys[b]) })
+		sorted := make([]string, len(strs))
+		for i, j := range order {
+			sorted[i] = strs[j]
+		}
+		strs = sorted
+	}
+	return strs, nil
+}
+
+// remainingEntries returns the entries of a field with the "remaining"
+// option, which are written inline in the enclosing section rather than
+// under a key of their own.
+func (e *encodeState) remainingEntries(v reflect.Value, indent string) ([]string, error) {
+	if v.Type() == valueType {
+		cv := v.Interface().(Value)
+		switch cv.Kind {
+		case EmptyValue:
+			return nil, nil
+		case MapValue:
+			return e.conlEntries(&cv, indent)
+		}
+		return nil, fmt.Errorf("remaining field must be a map, not %s", cv.Kind)
+	}
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("remaining field must be a map with string keys or a conl.Value, not %v", v.Type())
+	}
+	return e.mapEntries(v, indent)
+}
+
+// docComment formats the doc tag of a struct field as comment lines
+// to precede the field at the given indent.
+func docComment(doc, indent string) string {
+	result := ""
+	for _, line := range strings.Split(strings.TrimRight(doc, "\n"), "\n") {
+		if line = strings.TrimRight(line, " \t\r"); line != "" {
+			line = " " + line
+		}
+		result += ";" + line + "\n" + indent
+	}
+	return result
+}
+
+// joinSection joins the entries of a section. An empty section is
+// represented by a comment unless OmitEmptyComment is set.
+func (e *encodeState) joinSection(strs []string, indent string) string {
+	if len(strs) == 0 {
+		if e.opts.OmitEmptyComment {
+			return ""
+		}
+		return indent + "; empty"
+	}
+	var result strings.Builder
+	result.WriteString(indent + strs[0])
+	for i := 1; i < len(strs); i++ {
+		if e.opts.BlankLines && indent == "" && (strings.Contains(strs[i-1], "\n") || strings.Contains(strs[i], "\n")) {
+			result.WriteString("\n")
+		}
+		result.WriteString("\n")
+		result.WriteString(indent)
+		result.WriteString(strs[i])
+	}
+	return result.String()
+}
+
+// marshalTokens renders the tokens returned by m in the same way as
+// marshalValue; indent is the indentation of any nested lines.
+func (e *encodeState) marshalTokens(m Marshaler, indent, hint string) (string, bool, error) {
+	tokens, err := m.MarshalCONL()
+	if err != nil {
+		return "", false, err
+	}
+	for _, token := range tokens {
+		switch token.Kind {
+		case Comment:
+			continue
+		case MultilineHint:
+			hint = token.Content
+			continue
+		case NoValue:
+			return "", false, nil
+		case Scalar:
+			return quoteString(token.Content), true, nil
+		case MultilineScalar:
+			if hint == "" && !strings.Contains(token.Content, "\n") && canBeMultiline(token.Content) {
+				return `"""` + "\n" + indent + token.Content, true, nil
+			}
+			return quoteValue(token.Content, indent, hint), true, nil
+		}
+
+		output := &strings.Builder{}
+		enc := NewEncoder(output)
+		enc.SetIndent(e.opts.Indent)
+		for _, token := range tokens {
+			if err := enc.WriteToken(token); err != nil {
+				return "", false, fmt.Errorf("%T: %w", m, err)
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			return "", false, err
+		}
+		lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+		for i, line := range lines {
+			if line != "" {
+				lines[i] = indent + line
+			}
+		}
+		return "\n" + strings.Join(lines, "\n"), false, nil
+	}
+	return "", false, nil
+}
+
+// marshalEntry returns an entry with the given (already quoted) key,
+// or "=" for a list item.
+func (e *encodeState) marshalEntry(key string, value any, indent, hint string) (string, error) {
+	v, eq, err := e.marshalValue(value, indent, hint)
+	if err != nil {
+		return "", err
+	}
+	if eq && key == "=" {
+		return key + " " + v, nil
+	}
+	if eq {
+		return key + " = " + v, nil
+	}
+	return key + v, nil
+}
+
 // Marshal converts a go value to a CONL document.
 //
+// Struct fields are written in the order they are declared, using the name from
+// a `conl:"name"` or `json:"name"` tag if present. The options "omitempty" (to skip
+// zero values) and "hint=" (to set the multiline hint of strings) are supported.
+// A `doc:"..."` tag is written as a comment above the field, one line per line of
+// the tag. The entries of a field with the "remaining" option are written inline,
+// as if they were fields of the struct.
+// The fields of embedded structs are promoted to the containing struct, as with
+// [encoding/json].
+//
 // It returns an error if the value could not be marshaled (for example if it
 // contains a channel or a func).
 func Marshal(v any) ([]byte, error) {
-	str, err := marshalSection(v, "")
+	return MarshalWithOptions(v, MarshalOptions{})
+}
+
+// MarshalWithOptions is like [Marshal], but allows the output to be customized.
+func MarshalWithOptions(v any, opts MarshalOptions) ([]byte, error) {
+	if opts.Indent == "" {
+		opts.Indent = "  "
+	}
+	if strings.Trim(opts.Indent, " \t") != "" {
+		return nil, fmt.Errorf("invalid indent %q", opts.Indent)
+	}
+	e := &encodeState{opts: opts}
+	str, err := e.marshalSection(v, "")
+	if str == "" {
+		return []byte{}, err
+	}
 	return []byte(str + "\n"), err
 }
 
+// Marshaler is implemented by types that want to customize their CONL
+// serialization. The returned tokens describe the value in the same form
+// that an [Unmarshaler] receives it: either a single [Scalar] or [MultilineScalar]
+// (optionally preceded by a [MultilineHint]), [NoValue], or a sequence of
+// [MapKey] or [ListItem] entries with their values, using [Indent] and [Outdent]
+// for nested sections. Sections may also contain [Comment] tokens.
+// The tokens are rendered with an [Encoder], so Lno, Col and quoting can be ignored.
+type Marshaler interface {
+	MarshalCONL() ([]Token, error)
+}
+
 // Unmarshaler is implemented by types that want to customize their CONL
 // parsing. The provided iterator can be re-used (for example, using [conl.UnmarshalCONL]).
 // The provided tokens have been pre-processed as described by [Tokens], and additionally
@@ -248,14 +604,37 @@ type Unmarshaler interface {
 //
 // For struct fields, CONL will first look for the name in a `conl:"name"` tag,
 // then in a `json:"name"` tag, and finally use the snake_case version of the field
-// name or the field name itself.
+// name or the field name itself ([UnmarshalOptions].Naming can be used to accept
+// other conventions, such as "space case" keys). As with [encoding/json], the
+// fields of embedded structs are promoted to the containing struct.
+//
+// Fields with a "default=" option (for example `conl:"timeout,default=30s"`)
+// are set to the default if the key is absent or has no value, unless the field
+// already has a non-zero value. The default extends to the end of the tag, and
+// is parsed in the same way as a value in the document. An invalid default is
+// reported as an error naming the struct type and tag.
+//
+// A field with the "remaining" option (for example `conl:",remaining"`) collects
+// any keys that do not match another field. It must be a map with string keys
+// or a [Value]. Otherwise unknown keys are an error, unless
+// [UnmarshalOptions].IgnoreUnknownFields is set.
+//
+// Fields with the "required" option (for example `conl:"url,required"`) must be
+// present in the document. If any are missing, Unmarshal reports all of them at
+// the line of the enclosing key (or line 1 for the top level). A required key
+// that is present with no value is reported as missing at its own line.
 //
 // When unmarshalling into an interface, CONL maps will be unmarshalled into
 // a map[string]any, lists will be unmarshalled into []any, and scalars will
-// be unmarshalled to string.
+// be unmarshalled to string (see [UnmarshalOptions] for inferring other types).
+// To preserve key order, positions and comments unmarshal into a [Value] instead.
 //
 // If the CONL document is invalid, or doesn't match the type of v, then an
-// error will be returned.
+// error will be returned. Syntax errors wrap a [*SyntaxError], and otherwise
+// the error is an [*UnknownFieldError], a [*MissingFieldError] or a [*TypeError]
+// which include the line number and the path to the problem (for example
+// "servers[2].port"). Missing fields, and every error if
+// [UnmarshalOptions].AllErrors is set, are combined with [errors.Join].
 func Unmarshal(data []byte, v any) error {
 	return UnmarshalCONL(Tokens(data), v)
 }
@@ -264,68 +643,251 @@ func Unmarshal(data []byte, v any) error {
 // stream of tokens (for example implementations of [Unmarshaler] might want
 // to use this).
 func UnmarshalCONL(tok iter.Seq[Token], v any) error {
+	return unmarshalTokens(tok, v, UnmarshalOptions{})
+}
+
+// UnmarshalOptions controls the behavior of [UnmarshalWithOptions]
+// and [Decoder.Decode]. The zero value behaves like [Unmarshal].
+type UnmarshalOptions struct {
+	// IgnoreUnknownFields skips keys that do not match a field of the
+	// struct being decoded, instead of returning an error.
+	IgnoreUnknownFields bool
+	// InferTypes changes the type of scalars unmarshalled into an interface:
+	// "true" and "false" become a bool, integers become an int64, and other
+	// numbers become a float64. Other scalars (including integers that do
+	// not fit in an int64, and numbers out of the range of a float64)
+	// remain as strings.
+	InferTypes bool
+	// UseNumber is like InferTypes, except that numbers become a [Number],
+	// which retains their original text.
+	UseNumber bool
+	// Naming determines the keys that match struct fields that do not
+	// have a name in their tag, in addition to the Go field name.
+	Naming NamingPolicy
+	// AllErrors continues decoding after a value cannot be unmarshalled,
+	// and returns every error found, joined with [errors.Join]. By default
+	// only the first error is returned.
+	AllErrors bool
+}
+
+// UnmarshalWithOptions is like [Unmarshal], but allows its behavior to be customized.
+func UnmarshalWithOptions(data []byte, v any, opts UnmarshalOptions) error {
+	return unmarshalTokens(Tokens(data), v, opts)
+}
+
+func unmarshalTokens(tok iter.Seq[Token], v any, opts UnmarshalOptions) error {
 	value := reflect.ValueOf(v)
 	if value.Kind() != reflect.Ptr || value.IsNil() {
 		return fmt.Errorf("invalid target, must be a non-nil pointer")
 	}
 
-	iter, done := iter.Pull(tok)
+	next, done := iter.Pull(tok)
 	defer done()
-	lastLine := 0
-	tokenErr := error(nil)
-	nextToken := func() Token {
-		for {
-			token, valid := iter()
-			if token.Error != nil {
-				tokenErr = fmt.Errorf("%v: %s", token.Lno, token.Error)
-				valid = false
-			}
-			if !valid {
-				return Token{Lno: lastLine, Kind: Outdent, Content: ""}
-			}
-			if token.Kind == Comment || token.Kind == MultilineHint {
-				continue
+	d := &decodeState{next: next, opts: opts, keyLno: 1}
+	err := d.unmarshalValue(value.Elem())
+	if d.tokenErr != nil {
+		return d.tokenErr
+	}
+	if err != nil && !d.opts.AllErrors {
+		return err
+	}
+	if err != nil {
+		d.errs = append(d.errs, err)
+	}
+	return errors.Join(d.errs...)
+}
+
+// decodeState holds the state of a single call to [UnmarshalCONL].
+type decodeState struct {
+	opts     UnmarshalOptions
+	next     func() (Token, bool)
+	peeked   []Token
+	lastLine int
+	tokenErr error
+	// keyLno is the line of the most recent [MapKey] or [ListItem]
+	// (or 1 at the top level), used to report missing fields.
+	keyLno int
+	// keyCol is the column of the most recent [MapKey] or [ListItem]
+	// (or 0 at the top level).
+	keyCol int
+	// depth is the number of [Indent] tokens returned, less the number
+	// of [Outdent] tokens.
+	depth int
+	// path is the path to the value being decoded, which is formatted
+	// (for example as "servers[2].port") only if there is an error.
+	path []pathSegment
+	// errs are the errors for any missing required fields, and any other
+	// errors if [UnmarshalOptions].AllErrors is set, which are collected so
+	// that they can all be reported.
+	errs []error
+
+	// comments are only collected while decoding a [Value].
+	keepComments int
+	comments     []Token
+	// hint is the multiline hint of the most recently returned scalar
+	hint        string
+	pendingHint string
+	// lastEntry is the most recently decoded entry of a [Value], used to
+	// attach comments that appear on the same line.
+	lastEntry *ValueEntry
+}
+
+// nextToken returns the next token. [Comment] and [MultilineHint] tokens are
+// skipped, and [MultilineScalar] tokens are returned as [Scalar]. Once the
+// input is exhausted (or an error is found), [Outdent] is returned.
+func (d *decodeState) nextToken() Token {
+	token := Token{}
+	if len(d.peeked) > 0 {
+		token = d.peeked[len(d.peeked)-1]
+		d.peeked = d.peeked[:len(d.peeked)-1]
+	} else {
+		token = d.pull()
+	}
+	switch token.Kind {
+	case MapKey, ListItem:
+		d.keyLno, d.keyCol = token.Lno, token.Col
+	case Indent:
+		d.depth++
+	case Outdent:
+		d.depth--
+	}
+	return token
+}
+
+// pull returns the next token from the underlying iterator.
+func (d *decodeState) pull() Token {
+	for {
+		token, valid := d.next()
+		if token.Error != nil {
+			if d.tokenErr == nil {
+				d.tokenErr = tokenError(token)
 			}
-			if token.Kind == MultilineScalar {
-				token.Kind = Scalar
+			valid = false
+		}
+		if !valid {
+			return Token{Lno: d.lastLine, Kind: Outdent, Content: ""}
+		}
+		switch token.Kind {
+		case Comment:
+			if d.keepComments > 0 {
+				d.comments = append(d.comments, token)
 			}
-			lastLine = token.Lno
-			return token
+			continue
+		case MultilineHint:
+			d.pendingHint = token.Content
+			continue
+		case MultilineScalar:
+			token.Kind = Scalar
+			d.hint, d.pendingHint = d.pendingHint, ""
+		case Scalar:
+			d.hint = ""
 		}
+		d.lastLine = token.Lno
+		return token
 	}
-	err := unmarshalValue(nextToken, value.Elem())
-	if tokenErr != nil {
-		return tokenErr
-	}
-	if err != nil {
-		return err
+}
+
+// peekToken returns the next token without consuming it.
+func (d *decodeState) peekToken() Token {
+	if len(d.peeked) > 0 {
+		return d.peeked[len(d.peeked)-1]
 	}
-	return nil
+	token := d.pull()
+	d.peeked = append(d.peeked, token)
+	return token
 }
 
-func peekToken(nextToken func() Token) (Token, func() Token) {
-	t := nextToken()
-	first := true
-	return t, func() Token {
-		if first {
-			first = false
-			return t
+// scalar returns a new decodeState that yields only the given token,
+// which is the value at the current path.
+func (d *decodeState) scalar(token Token) *decodeState {
+	done := false
+	return &decodeState{next: func() (Token, bool) {
+		if done {
+			return Token{}, false
 		}
-		return nextToken()
+		done = true
+		return token, true
+	}, lastLine: token.Lno, path: d.path, opts: d.opts}
+}
+
+// A pathSegment is a map key, or a list index if index is not -1.
+type pathSegment struct {
+	key   string
+	index int
+}
+
+func keySegment(key string) pathSegment {
+	return pathSegment{key: key, index: -1}
+}
+
+// currentPath returns the path to the value being decoded.
+func (d *decodeState) currentPath() string {
+	var b strings.Builder
+	for _, s := range d.path {
+		if s.index >= 0 {
+			fmt.Fprintf(&b, "[%d]", s.index)
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(s.key)
+	}
+	return b.String()
+}
+
+// keyPath returns the path to key within the value being decoded.
+func (d *decodeState) keyPath(key string) string {
+	if path := d.currentPath(); path != "" {
+		return path + "." + key
 	}
+	return key
 }
 
-func tokenIter(nextToken func() Token) iter.Seq[Token] {
-	token := nextToken()
+// typeError returns a [TypeError] for the value being decoded into v.
+func (d *decodeState) typeError(lno int, v reflect.Value, err error) error {
+	return &TypeError{Lno: lno, Path: d.currentPath(), Type: v.Type(), Err: err}
+}
+
+// unmarshalEntry decodes the value of the map key or list item identified
+// by segment into v.
+func (d *decodeState) unmarshalEntry(v reflect.Value, segment pathSegment) error {
+	depth := d.depth
+	d.path = append(d.path, segment)
+	err := d.unmarshalValue(v)
+	d.path = d.path[:len(d.path)-1]
+	return d.handleError(err, depth)
+}
+
+// handleError returns err, unless [UnmarshalOptions].AllErrors is set. In
+// that case err is recorded, and the rest of the value that caused it
+// (whose key is at depth) is skipped so that decoding can continue.
+func (d *decodeState) handleError(err error, depth int) error {
+	if err == nil || !d.opts.AllErrors || d.tokenErr != nil {
+		return err
+	}
+	d.errs = append(d.errs, err)
+	switch d.peekToken().Kind {
+	case Scalar, NoValue, Indent:
+		d.nextToken()
+	}
+	for d.depth > depth {
+		d.nextToken()
+	}
+	return nil
+}
+
+func (d *decodeState) tokenIter() iter.Seq[Token] {
+	token := d.nextToken()
 	if token.Kind == Indent {
-		token = nextToken()
+		token = d.nextToken()
 	}
 	tokens := []Token{token}
 	if token.Kind == ListItem || token.Kind == MapKey {
 		indentCount := 0
 	loop:
 		for {
-			token = nextToken()
+			token = d.nextToken()
 			switch token.Kind {
 			case Indent:
 				indentCount += 1
@@ -341,122 +903,241 @@ func tokenIter(nextToken func() Token) iter.Seq[Token] {
 	return slices.Values(tokens)
 }
 
-func unmarshalValue(nextToken func() Token, v reflect.Value) error {
+func (d *decodeState) unmarshalValue(v reflect.Value) error {
 	if !v.CanSet() {
 		panic(fmt.Errorf("cannot set value of type: %v", v.Type()))
 	}
+	if v.Type() == valueType {
+		return d.unmarshalConlValue(v.Addr().Interface().(*Value))
+	}
+	if v.Type() == multilineType {
+		return d.unmarshalMultiline(v.Addr().Interface().(*Multiline))
+	}
+	if p, ok := v.Addr().Interface().(positioned); ok {
+		return d.unmarshalPositioned(p)
+	}
+	if om, ok := v.Addr().Interface().(orderedMap); ok {
+		keyType, valueType := om.types()
+		return d.unmarshalEntries(keyType, valueType, om.setValue)
+	}
 	if cu, ok := v.Addr().Interface().(Unmarshaler); ok {
-		if err := cu.UnmarshalCONL(tokenIter(nextToken)); err != nil {
+		if err := cu.UnmarshalCONL(d.tokenIter()); err != nil {
 			return err
 		}
 		return nil
 	}
 
 	if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
-		token, next := peekToken(nextToken)
-		if token.Kind == Scalar {
+		if token := d.peekToken(); token.Kind == Scalar {
+			d.nextToken()
 			if err := tu.UnmarshalText([]byte(token.Content)); err != nil {
-				return fmt.Errorf("%d: %w", token.Lno, err)
+				return d.typeError(token.Lno, v, err)
 			}
 			return nil
 		}
-		nextToken = next
 	}
 
 	switch v.Kind() {
 	case reflect.Struct:
-		return unmarshalStruct(nextToken, v)
+		return d.unmarshalStruct(v)
 	case reflect.Map:
-		return unmarshalMap(nextToken, v)
+		return d.unmarshalMap(v)
 	case reflect.Interface:
-		return unmarshalInterface(nextToken, v)
+		return d.unmarshalInterface(v)
 	case reflect.Ptr:
 
 		if _, ok := v.Interface().(Unmarshaler); !ok {
-			t, next := peekToken(nextToken)
-			if t.Kind == NoValue {
+			if d.peekToken().Kind == NoValue {
+				d.nextToken()
 				return nil
 			}
-			nextToken = next
 		}
 		if v.IsNil() {
 			v.Set(reflect.New(v.Type().Elem()))
 		}
-		return unmarshalValue(nextToken, v.Elem())
+		return d.unmarshalValue(v.Elem())
 	case reflect.Array:
-		return unmarshalArray(nextToken, v)
+		return d.unmarshalArray(v)
 	case reflect.Slice:
-		return unmarshalSlice(nextToken, v)
+		return d.unmarshalSlice(v)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
 		reflect.Float32, reflect.Float64,
 		reflect.Complex64, reflect.Complex128,
 		reflect.Bool,
 		reflect.String:
-		token := nextToken()
+		token := d.nextToken()
 		if token.Kind == Scalar {
-			return unmarshalScalar(token.Lno, token.Content, v)
+			if err := unmarshalScalar(token.Content, v); err != nil {
+				return d.typeError(token.Lno, v, err)
+			}
+			return nil
 		}
-		return fmt.Errorf("%d: expected value", token.Lno)
+		return d.typeError(token.Lno, v, errors.New("expected value"))
 	}
 
 	return fmt.Errorf("unsupported type: %v", v.Type())
 }
 
-func unmarshalStruct(nextToken func() Token, v reflect.Value) error {
-	t := v.Type()
-	fieldMap := make(map[string]reflect.Value)
-
-	for i := 0; i < t.NumField(); i++ {
-		field := v.Field(i)
-		fieldType := t.Field(i)
-
-		if fieldType.PkgPath != "" {
-			continue
-		}
-
-		if tag, ok := fieldType.Tag.Lookup("conl"); ok {
-			if tag == "-" {
-				continue
-			}
-			name, _, _ := strings.Cut(tag, ",")
-			fieldMap[name] = field
-			continue
-		}
-
-		if tag, ok := fieldType.Tag.Lookup("json"); ok {
-			if tag == "-" {
-				continue
-			}
-			name, _, _ := strings.Cut(tag, ",")
-			fieldMap[name] = field
-			continue
-		}
-
-		fieldMap[fieldType.Name] = field
-		fieldMap[toSnakeCase(fieldType.Name)] = field
-	}
+func (d *decodeState) unmarshalStruct(v reflect.Value) error {
+	lno := d.keyLno
+	cached := cachedFields(v.Type(), d.opts.Naming)
+	fields, fieldMap, remaining := cached.list, cached.byName, cached.remaining
+	seen := make([]bool, len(fields))
 
 	for {
-		token := nextToken()
+		token := d.nextToken()
 		switch token.Kind {
 		case Indent:
 			continue
 		case MapKey:
-			field, ok := fieldMap[token.Content]
+			i, ok := fieldMap[token.Content]
+			if !ok && d.opts.Naming == CaseInsensitive {
+				for name, j := range fieldMap {
+					if strings.EqualFold(name, token.Content) && (!ok || j < i) {
+						i, ok = j, true
+					}
+				}
+			}
+			if !ok && remaining >= 0 {
+				if err := d.unmarshalRemaining(fieldByIndex(v, fields[remaining].index), token); err != nil {
+					return err
+				}
+				continue
+			}
+			if !ok && d.opts.IgnoreUnknownFields {
+				for range d.tokenIter() {
+				}
+				continue
+			}
 			if !ok {
-				return fmt.Errorf("%d: unknown field %s", token.Lno, token.Content)
+				err := &UnknownFieldError{Lno: token.Lno, Path: d.keyPath(token.Content), Field: token.Content}
+				if !d.opts.AllErrors {
+					return err
+				}
+				d.errs = append(d.errs, err)
+				for range d.tokenIter() {
+				}
+				continue
 			}
-			if err := unmarshalValue(nextToken, field); err != nil {
+			seen[i] = true
+			field := fieldByIndex(v, fields[i].index)
+			if fields[i].hasDefault && d.peekToken().Kind == NoValue {
+				d.nextToken()
+				if err := d.applyDefault(field, v.Type(), fields[i], token); err != nil {
+					return err
+				}
+				continue
+			}
+			if fields[i].required && d.peekToken().Kind == NoValue {
+				d.nextToken()
+				d.errs = append(d.errs, &MissingFieldError{Lno: token.Lno, Path: d.keyPath(token.Content), Field: fields[i].name})
+				continue
+			}
+			if err := d.unmarshalEntry(field, keySegment(token.Content)); err != nil {
 				return err
 			}
 		case Outdent, NoValue:
+			for i, f := range fields {
+				if seen[i] {
+					continue
+				}
+				if f.required {
+					d.errs = append(d.errs, &MissingFieldError{Lno: lno, Path: d.keyPath(f.name), Field: f.name})
+				}
+				field, ok := fieldValue(v, f.index)
+				if f.hasDefault {
+					field, ok = fieldByIndex(v, f.index), true
+				}
+				if !ok {
+					continue
+				}
+				if err := d.applyDefault(field, v.Type(), f, Token{}); err != nil {
+					return err
+				}
+			}
 			return nil
 
 		default:
-			return fmt.Errorf("%d: unexpected %v, expected %v", token.Lno, token.Kind, v.Type())
+			return d.typeError(token.Lno, v, fmt.Errorf("unexpected %v, expected %v", token.Kind, v.Type()))
+		}
+	}
+}
+
+// applyDefault sets a field of the struct type t that was not present in the
+// document (or had no value) to its default value, if it has one and the field
+// is zero. The default is parsed in the same way as a scalar in the document,
+// at the position of key if the key was present, or with no position (so any
+// [Positioned] is left zero) if it was not. If the field is a struct with no
+// default, the defaults of its fields are applied instead.
+//
+// An invalid default is a mistake in the struct tag rather than in the
+// document, so the error has no line number.
+func (d *decodeState) applyDefault(v reflect.Value, t reflect.Type, f field, key Token) error {
+	if f.hasDefault {
+		if !v.IsZero() {
+			return nil
+		}
+		sub := d.scalar(Token{Lno: key.Lno, Col: key.Col, Kind: Scalar, Content: f.def})
+		sub.keyLno, sub.keyCol = key.Lno, key.Col
+		err := sub.unmarshalValue(v)
+		if te := (*TypeError)(nil); errors.As(err, &te) {
+			err = te.Err
 		}
+		if err != nil {
+			return fmt.Errorf("invalid default for %v.%s in tag `%s`: %w", t, f.sf.Name, f.sf.Tag, err)
+		}
+		return nil
 	}
+	if v.Kind() != reflect.Struct || v.Type() == valueType || v.Type() == multilineType {
+		return nil
+	}
+	if _, ok := v.Addr().Interface().(Unmarshaler); ok {
+		return nil
+	}
+	if _, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return nil
+	}
+	for _, f := range cachedFields(v.Type(), DefaultNaming).list {
+		if field, ok := fieldValue(v, f.index); ok {
+			if err := d.applyDefault(field, v.Type(), f, Token{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unmarshalRemaining decodes the value of key into a field with the
+// "remaining" option, which must be a map with string keys or a [Value].
+func (d *decodeState) unmarshalRemaining(v reflect.Value, key Token) error {
+	if v.Type() == valueType {
+		cv := v.Addr().Interface().(*Value)
+		if cv.Kind == EmptyValue {
+			*cv = Value{Kind: MapValue, Lno: key.Lno, Col: key.Col}
+		}
+		entry := &ValueEntry{Key: key.Content, Lno: key.Lno, Col: key.Col}
+		cv.Entries = append(cv.Entries, entry)
+		if err := d.unmarshalConlValue(&entry.Value); err != nil {
+			return err
+		}
+		if entry.Value.Kind == EmptyValue {
+			entry.Value.Lno, entry.Value.Col = key.Lno, key.Col
+		}
+		return nil
+	}
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return d.typeError(key.Lno, v, fmt.Errorf("remaining field must be a map with string keys or a conl.Value, not %v", v.Type()))
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	value := reflect.New(v.Type().Elem()).Elem()
+	if err := d.unmarshalEntry(value, keySegment(key.Content)); err != nil {
+		return err
+	}
+	v.SetMapIndex(reflect.ValueOf(key.Content).Convert(v.Type().Key()), value)
+	return nil
 }
 
 func toSnakeCase(s string) string {
@@ -470,9 +1151,9 @@ func toSnakeCase(s string) string {
 	return result.String()
 }
 
-func unmarshalInterface(nextToken func() Token, v reflect.Value) error {
+func (d *decodeState) unmarshalInterface(v reflect.Value) error {
 	for {
-		token := nextToken()
+		token := d.nextToken()
 		switch token.Kind {
 		case Indent:
 			continue
@@ -481,93 +1162,130 @@ func unmarshalInterface(nextToken func() Token, v reflect.Value) error {
 			v.Set(m)
 			key := reflect.ValueOf(token.Content)
 			value := reflect.New(m.Type().Elem()).Elem()
-			if err := unmarshalValue(nextToken, value); err != nil {
+			if err := d.unmarshalEntry(value, keySegment(token.Content)); err != nil {
 				return err
 			}
 			m.SetMapIndex(key, value)
-			return unmarshalMap(nextToken, m)
+			return d.unmarshalMap(m)
 		case ListItem:
 			s := reflect.ValueOf(&[]any{}).Elem()
 			value := reflect.New(s.Type().Elem()).Elem()
-			if err := unmarshalValue(nextToken, value); err != nil {
+			if err := d.unmarshalEntry(value, pathSegment{index: 0}); err != nil {
 				return err
 			}
 			s.Set(reflect.Append(s, value))
 
-			if err := unmarshalSlice(nextToken, s); err != nil {
+			if err := d.unmarshalSlice(s); err != nil {
 				return err
 			}
 			v.Set(s)
 			return nil
 		case Scalar:
+			if d.opts.InferTypes || d.opts.UseNumber {
+				v.Set(reflect.ValueOf(d.inferScalar(token.Content)))
+				return nil
+			}
 			v.Set(reflect.ValueOf(token.Content))
 			return nil
 		case Outdent, NoValue:
 			return nil
 		default:
-			return fmt.Errorf("%d: unexpected %v", token.Lno, token.Kind)
+			return d.typeError(token.Lno, v, fmt.Errorf("unexpected %v", token.Kind))
 		}
 	}
 }
 
-func unmarshalMap(nextToken func() Token, v reflect.Value) error {
-	keyType := v.Type().Key()
-	valueType := v.Type().Elem()
+func (d *decodeState) unmarshalMap(v reflect.Value) error {
+	return d.unmarshalEntries(v.Type().Key(), v.Type().Elem(), func(key, value reflect.Value) {
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		v.SetMapIndex(key, value)
+	})
+}
 
+// unmarshalEntries decodes a map, calling set for each key and value.
+func (d *decodeState) unmarshalEntries(keyType, valueType reflect.Type, set func(key, value reflect.Value)) error {
 	for {
-		token := nextToken()
+		token := d.nextToken()
 		switch token.Kind {
 		case Indent:
 			continue
 		case MapKey:
-			if v.IsNil() {
-				v.Set(reflect.MakeMap(v.Type()))
-			}
 			key := reflect.New(keyType).Elem()
-			tok := Token{Lno: token.Lno, Content: token.Content, Kind: Scalar, Error: nil}
-			if err := unmarshalValue(func() Token { return tok }, key); err != nil {
-				return err
+			d.path = append(d.path, keySegment(token.Content))
+			err := d.unmarshalKey(token, key)
+			d.path = d.path[:len(d.path)-1]
+			if err != nil {
+				if err := d.handleError(err, d.depth); err != nil {
+					return err
+				}
+				continue
 			}
 			value := reflect.New(valueType).Elem()
-			if err := unmarshalValue(nextToken, value); err != nil {
+			if err := d.unmarshalEntry(value, keySegment(token.Content)); err != nil {
 				return err
 			}
-			v.SetMapIndex(key, value)
+			set(key, value)
 		case Outdent, NoValue:
 			return nil
 
 		default:
-			return fmt.Errorf("%d: unexpected %s, expected %s", token.Lno, token.Kind, MapKey)
+			return &TypeError{Lno: token.Lno, Path: d.currentPath(), Type: valueType, Err: fmt.Errorf("unexpected %s, expected %s", token.Kind, MapKey)}
 		}
 	}
 }
 
-func unmarshalSlice(nextToken func() Token, v reflect.Value) error {
+// unmarshalKey decodes the map key token into v. Composite keys are split
+// into components, each of which is decoded like a scalar.
+func (d *decodeState) unmarshalKey(token Token, v reflect.Value) error {
+	tok := Token{Lno: token.Lno, Content: token.Content, Kind: Scalar, Error: nil}
+	if !isCompositeKey(v.Type()) {
+		return d.scalar(tok).unmarshalValue(v)
+	}
+	parts, err := splitKey(token.Content)
+	if err != nil {
+		return d.typeError(token.Lno, v, err)
+	}
+	components := keyComponents(v)
+	if len(parts) != len(components) {
+		return d.typeError(token.Lno, v, fmt.Errorf("expected %d components in key, got %d", len(components), len(parts)))
+	}
+	for i, c := range components {
+		tok.Content = parts[i]
+		if err := d.scalar(tok).unmarshalValue(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decodeState) unmarshalSlice(v reflect.Value) error {
 	elemType := v.Type().Elem()
 
 	if elemType.Kind() == reflect.Uint8 {
-		token := nextToken()
+		token := d.nextToken()
 		if token.Kind == Scalar {
 			r := strings.NewReplacer(" ", "", "\t", "", "\n", "")
 			input := r.Replace(token.Content)
 			output, err := base64.RawStdEncoding.DecodeString(input)
 			if err != nil {
-				return fmt.Errorf("%d: %w", token.Lno, err)
+				return d.typeError(token.Lno, v, err)
 			}
 			v.Set(reflect.ValueOf(output))
 			return nil
 		}
-		return fmt.Errorf("%d: expected value", token.Lno)
+		return d.typeError(token.Lno, v, errors.New("expected value"))
 	}
 
 	for {
-		token := nextToken()
+		token := d.nextToken()
 		switch token.Kind {
 		case Indent:
 			continue
 		case ListItem:
 			elem := reflect.New(elemType).Elem()
-			if err := unmarshalValue(nextToken, elem); err != nil {
+			if err := d.unmarshalEntry(elem, pathSegment{index: v.Len()}); err != nil {
 				return err
 			}
 			v.Set(reflect.Append(v, elem))
@@ -575,25 +1293,33 @@ func unmarshalSlice(nextToken func() Token, v reflect.Value) error {
 			return nil
 
 		default:
-			return fmt.Errorf("%d: unexpected %s, expected %s", token.Lno, token.Kind, ListItem)
+			return d.typeError(token.Lno, v, fmt.Errorf("unexpected %s, expected %s", token.Kind, ListItem))
 		}
 	}
 }
 
-func unmarshalArray(nextToken func() Token, v reflect.Value) error {
+func (d *decodeState) unmarshalArray(v reflect.Value) error {
 	elemType := v.Type().Elem()
 
 	i := 0
 	for {
-		token := nextToken()
+		token := d.nextToken()
 		switch token.Kind {
+		case Indent:
+			continue
 		case ListItem:
 			elem := reflect.New(elemType).Elem()
-			if err := unmarshalValue(nextToken, elem); err != nil {
+			if err := d.unmarshalEntry(elem, pathSegment{index: i}); err != nil {
 				return err
 			}
 			if v.Len() <= i {
-				return fmt.Errorf("%d: too many elements, limit %d", token.Lno, i)
+				d.path = append(d.path, pathSegment{index: i})
+				err := d.typeError(token.Lno, v, fmt.Errorf("too many elements, limit %d", v.Len()))
+				d.path = d.path[:len(d.path)-1]
+				if err := d.handleError(err, d.depth); err != nil {
+					return err
+				}
+				continue
 			}
 			v.Index(i).Set(elem)
 			i += 1
@@ -601,12 +1327,27 @@ func unmarshalArray(nextToken func() Token, v reflect.Value) error {
 			return nil
 
 		default:
-			return fmt.Errorf("%d: unexpected %s, expected list", token.Lno, token.Kind)
+			return d.typeError(token.Lno, v, fmt.Errorf("unexpected %s, expected list", token.Kind))
 		}
 	}
 }
 
-func unmarshalScalar(lno int, s string, v reflect.Value) error {
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// unmarshalScalar parses s into v. Errors do not include the position.
+func unmarshalScalar(s string, v reflect.Value) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
 	switch v.Kind() {
 	case reflect.String:
 		v.SetString(s)
@@ -616,7 +1357,7 @@ func unmarshalScalar(lno int, s string, v reflect.Value) error {
 			return err
 		}
 		if v.OverflowInt(i) {
-			return fmt.Errorf("%d: invalid %s: %v", lno, v.Type(), i)
+			return fmt.Errorf("invalid %s: %v", v.Type(), i)
 		}
 		v.SetInt(i)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
@@ -625,7 +1366,7 @@ func unmarshalScalar(lno int, s string, v reflect.Value) error {
 			return err
 		}
 		if v.OverflowUint(u) {
-			return fmt.Errorf("%d: invalid %s: %v", lno, v.Type(), u)
+			return fmt.Errorf("invalid %s: %v", v.Type(), u)
 		}
 		v.SetUint(u)
 	case reflect.Float32, reflect.Float64:
@@ -634,7 +1375,7 @@ func unmarshalScalar(lno int, s string, v reflect.Value) error {
 			return err
 		}
 		if v.OverflowFloat(f) {
-			return fmt.Errorf("%d: invalid %s: %v", lno, v.Type(), f)
+			return fmt.Errorf("invalid %s: %v", v.Type(), f)
 		}
 		v.SetFloat(f)
 	case reflect.Complex64, reflect.Complex128:
@@ -643,7 +1384,7 @@ func unmarshalScalar(lno int, s string, v reflect.Value) error {
 			return err
 		}
 		if v.OverflowComplex(c) {
-			return fmt.Errorf("%d: invalid %s: %v", lno, v.Type(), c)
+			return fmt.Errorf("invalid %s: %v", v.Type(), c)
 		}
 		v.SetComplex(c)
 	case reflect.Bool:
@@ -653,7 +1394,7 @@ func unmarshalScalar(lno int, s string, v reflect.Value) error {
 		}
 		v.SetBool(b)
 	default:
-		return fmt.Errorf("%d: unsupported type %s", lno, v.Type())
+		return fmt.Errorf("unsupported type %s", v.Type())
 	}
 	return nil
 }
@@ -1,9 +1,13 @@
 package conl_test
 
 import (
+	"errors"
 	"fmt"
 	"iter"
+	"maps"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -602,3 +606,718 @@ map
 	}
 
 }
+
+type tagSet map[string]bool
+
+func (s tagSet) MarshalCONL() ([]conl.Token, error) {
+	if len(s) == 0 {
+		return []conl.Token{{Kind: conl.NoValue}}, nil
+	}
+	tokens := []conl.Token{{Kind: conl.Comment, Content: " tags"}}
+	for _, tag := range slices.Sorted(maps.Keys(s)) {
+		tokens = append(tokens, conl.Token{Kind: conl.ListItem}, conl.Token{Kind: conl.Scalar, Content: tag})
+	}
+	return tokens, nil
+}
+
+type query string
+
+func (q query) MarshalCONL() ([]conl.Token, error) {
+	return []conl.Token{{Kind: conl.MultilineHint, Content: "sql"}, {Kind: conl.MultilineScalar, Content: string(q)}}, nil
+}
+
+type badMarshaler struct{}
+
+func (b badMarshaler) MarshalCONL() ([]conl.Token, error) {
+	return []conl.Token{{Kind: conl.ListItem}, {Kind: conl.Indent}, {Kind: conl.Scalar, Content: "a"}}, nil
+}
+
+func TestMarshalCONL(t *testing.T) {
+	input := struct {
+		Tags   tagSet
+		Empty  tagSet
+		Nested map[string]tagSet
+		Query  query
+	}{
+		Tags:   tagSet{"b": true, "a = b": true},
+		Nested: map[string]tagSet{"x": {"c": true}},
+		Query:  "select 1",
+	}
+	expected := `Tags
+  ; tags
+  = "a = b"
+  = b
+Empty
+Nested
+  x
+    ; tags
+    = c
+Query = """sql
+  select 1
+`
+	bytes, err := conl.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bytes) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, bytes)
+	}
+
+	bytes, err = conl.Marshal(tagSet{"a": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bytes) != "; tags\n= a\n" {
+		t.Fatalf("unexpected output: %q", bytes)
+	}
+
+	if _, err := conl.Marshal(map[string]any{"a": badMarshaler{}}); err == nil || err.Error() != "conl_test.badMarshaler: 0: unexpected Value" {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestMarshalWithOptions(t *testing.T) {
+	input := struct {
+		Name  string
+		Ports map[string]int
+		Tags  []string
+		Empty []string
+		Last  bool
+	}{
+		Name:  "web",
+		Ports: map[string]int{"https": 443, "http": 80, "admin": 8080},
+		Tags:  []string{"a"},
+		Last:  true,
+	}
+
+	bytes, err := conl.MarshalWithOptions(input, conl.MarshalOptions{
+		Indent:           "\t",
+		CompareKeys:      func(a, b string) int { return strings.Compare(b, a) },
+		OmitEmptyComment: true,
+		BlankLines:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Name = web\n\nPorts\n\thttps = 443\n\thttp = 80\n\tadmin = 8080\n\nTags\n\t= a\n\nEmpty\nLast = true\n"
+	if string(bytes) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, bytes)
+	}
+
+	bytes, err = conl.MarshalWithOptions(input, conl.MarshalOptions{Indent: "    "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "Name = web\nPorts\n    admin = 8080\n    http = 80\n    https = 443\nTags\n    = a\nEmpty\n    ; empty\nLast = true\n"
+	if string(bytes) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, bytes)
+	}
+
+	if _, err := conl.MarshalWithOptions(input, conl.MarshalOptions{Indent: "--"}); err == nil || err.Error() != `invalid indent "--"` {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestMarshalDoc(t *testing.T) {
+	type server struct {
+		Host string `conl:"host" doc:"Address to bind to"`
+		Port int    `conl:"port" doc:"TCP port to listen on.\n\nUse 0 for any free port."`
+	}
+	input := struct {
+		Server server `conl:"server" doc:"The HTTP server"`
+		Debug  bool   `conl:"debug"`
+	}{Server: server{Host: "localhost", Port: 8080}}
+
+	bytes, err := conl.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `; The HTTP server
+server
+  ; Address to bind to
+  host = localhost
+  ; TCP port to listen on.
+  ;
+  ; Use 0 for any free port.
+  port = 8080
+debug = false
+`
+	if string(bytes) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, bytes)
+	}
+}
+
+type CommonOptions struct {
+	Verbose bool   `conl:"verbose"`
+	Name    string `conl:"name"`
+	Timeout int
+}
+
+type Logging struct {
+	Level string `conl:"level"`
+	Name  string `conl:"name"`
+}
+
+type common struct {
+	Verbose bool `conl:"verbose"`
+	secret  string
+}
+
+type hidden struct {
+	Level string `conl:"level"`
+}
+
+func TestEmbeddedStructs(t *testing.T) {
+	type config struct {
+		CommonOptions
+		*Logging
+		Named   CommonOptions `conl:"named"`
+		Timeout string
+	}
+
+	input := "verbose = true\nlevel = debug\nnamed\n  timeout = 5\ntimeout = 1m\n"
+	var output config
+	if err := conl.Unmarshal([]byte(input), &output); err != nil {
+		t.Fatal(err)
+	}
+	expected := config{
+		CommonOptions: CommonOptions{Verbose: true},
+		Logging:       &Logging{Level: "debug"},
+		Named:         CommonOptions{Timeout: 5},
+		Timeout:       "1m",
+	}
+	if !reflect.DeepEqual(output, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, output)
+	}
+	if err := conl.Unmarshal([]byte("name = x\n"), &output); err == nil || err.Error() != "1: unknown field name" {
+		t.Fatalf("expected conflicting field to be unknown, got %v", err)
+	}
+
+	bytes, err := conl.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := "verbose = true\nlevel = debug\nnamed\n  verbose = false\n  name = \"\"\n  Timeout = 5\nTimeout = 1m\n"
+	if string(bytes) != expectedOutput {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expectedOutput, bytes)
+	}
+
+	bytes, err = conl.Marshal(config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bytes), "level") {
+		t.Fatalf("expected nil embedded pointer to be skipped, got:\n%s", bytes)
+	}
+
+	type unexported struct {
+		common
+		*hidden
+		Name string `conl:"name"`
+	}
+	var private unexported
+	if err := conl.Unmarshal([]byte("verbose = true\nname = x\n"), &private); err != nil || !private.Verbose || private.Name != "x" {
+		t.Fatalf("expected unexported embedded struct to be promoted, got %#v, %v", private, err)
+	}
+	if err := conl.Unmarshal([]byte("level = debug\n"), &private); err == nil || err.Error() != "1: unknown field level" {
+		t.Fatalf("expected unexported embedded pointer to be skipped, got %v", err)
+	}
+	bytes, err = conl.Marshal(private)
+	if err != nil || string(bytes) != "verbose = true\nname = x\n" {
+		t.Fatalf("unexpected output %q, %v", bytes, err)
+	}
+}
+
+func TestRequiredFields(t *testing.T) {
+	type server struct {
+		Host string `conl:"host,required"`
+		Port int    `conl:"port,omitempty,required"`
+	}
+	type config struct {
+		DatabaseURL string   `conl:"database_url,required"`
+		Primary     server   `conl:"primary"`
+		Replicas    []server `conl:"replicas"`
+	}
+
+	input := "primary\n  host = a\nreplicas\n  =\n    host = b\n    port = 2\n  =\n    port = 3\n"
+	var output config
+	err := conl.Unmarshal([]byte(input), &output)
+	expected := "1: missing required field primary.port\n7: missing required field replicas[1].host\n1: missing required field database_url"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%v", expected, err)
+	}
+
+	input = "database_url\nprimary\n  host = a\n  port =\n"
+	err = conl.Unmarshal([]byte(input), &output)
+	expected = "1: missing required field database_url\n4: missing required field primary.port"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%v", expected, err)
+	}
+	var missing *conl.MissingFieldError
+	if !errors.As(err, &missing) || missing.Field != "database_url" {
+		t.Fatalf("expected MissingFieldError, got %#v", err)
+	}
+
+	input = "database_url = postgres://\nprimary\n  host = a\n  port = 1\n"
+	if err := conl.Unmarshal([]byte(input), &output); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	type config struct {
+		Name string `conl:"name"`
+	}
+	input := "name = a\nextra\n  nested = b\n  list\n    = c\nflag = d\n"
+
+	var output config
+	if err := conl.Unmarshal([]byte(input), &output); err == nil || err.Error() != "2: unknown field extra" {
+		t.Fatalf("expected error, got %v", err)
+	}
+	if err := conl.UnmarshalWithOptions([]byte(input), &output, conl.UnmarshalOptions{IgnoreUnknownFields: true}); err != nil || output.Name != "a" {
+		t.Fatalf("unexpected result %#v, %v", output, err)
+	}
+
+	dec := conl.NewDecoder(strings.NewReader(input))
+	dec.SetOptions(conl.UnmarshalOptions{IgnoreUnknownFields: true})
+	if err := dec.Decode(&output); err != nil {
+		t.Fatal(err)
+	}
+
+	var withMap struct {
+		Name  string         `conl:"name"`
+		Other map[string]any `conl:",remaining"`
+	}
+	if err := conl.Unmarshal([]byte(input), &withMap); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"extra": map[string]any{"nested": "b", "list": []any{"c"}},
+		"flag":  "d",
+	}
+	if withMap.Name != "a" || !reflect.DeepEqual(withMap.Other, expected) {
+		t.Fatalf("unexpected result %#v", withMap)
+	}
+
+	var withValue struct {
+		Name  string     `conl:"name"`
+		Other conl.Value `conl:",remaining"`
+	}
+	if err := conl.Unmarshal([]byte(input), &withValue); err != nil {
+		t.Fatal(err)
+	}
+	if withValue.Other.Kind != conl.MapValue || withValue.Other.Len() != 2 || withValue.Other.Get("flag").Scalar != "d" || withValue.Other.Get("extra").Get("nested").Lno != 3 {
+		t.Fatalf("unexpected result %#v", withValue.Other)
+	}
+
+	var invalid struct {
+		Other []string `conl:",remaining"`
+	}
+	if err := conl.Unmarshal([]byte(input), &invalid); err == nil || err.Error() != "1: remaining field must be a map with string keys or a conl.Value, not []string" {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestRemainingRoundTrip(t *testing.T) {
+	input := "name = a\nextra\n  nested = b\n  list\n    = c\nflag = d\n"
+
+	var withMap struct {
+		Name  string         `conl:"name"`
+		Other map[string]any `conl:",remaining"`
+	}
+	var withValue struct {
+		Name  string     `conl:"name"`
+		Other conl.Value `conl:",remaining"`
+	}
+	tests := []struct {
+		value    any
+		expected string
+	}{
+		{&withMap, "name = a\nextra\n  list\n    = c\n  nested = b\nflag = d\n"},
+		{&withValue, input},
+	}
+	for _, test := range tests {
+		if err := conl.Unmarshal([]byte(input), test.value); err != nil {
+			t.Fatal(err)
+		}
+		output, err := conl.Marshal(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(output) != test.expected {
+			t.Fatalf("expected:\n%s\ngot:\n%s", test.expected, output)
+		}
+		again := reflect.New(reflect.TypeOf(test.value).Elem()).Interface()
+		if err := conl.Unmarshal(output, again); err != nil {
+			t.Fatal(err)
+		}
+		if output, err := conl.Marshal(again); err != nil || string(output) != test.expected {
+			t.Fatalf("expected:\n%s\ngot:\n%s (%v)", test.expected, output, err)
+		}
+	}
+
+	withMap.Other, withValue.Other = nil, conl.Value{}
+	for _, value := range []any{withMap, withValue} {
+		output, err := conl.Marshal(value)
+		if err != nil || string(output) != "name = a\n" {
+			t.Fatalf("unexpected result %q, %v", output, err)
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	type limits struct {
+		Requests int    `conl:"requests,default=100"`
+		Burst    *int   `conl:"burst,default=10"`
+		Note     string `conl:"note"`
+	}
+	type config struct {
+		Port    int       `conl:"port,default=8080"`
+		Name    string    `conl:"name,default=a, b"`
+		Started time.Time `conl:"started,default=2024-11-01T16:00:00Z"`
+		Limits  limits    `conl:"limits"`
+		Others  []limits  `conl:"others"`
+	}
+
+	var output config
+	if err := conl.Unmarshal([]byte("port\nothers\n  =\n    requests = 5\n"), &output); err != nil {
+		t.Fatal(err)
+	}
+	ten := 10
+	expected := config{
+		Port:    8080,
+		Name:    "a, b",
+		Started: time.Date(2024, 11, 1, 16, 0, 0, 0, time.UTC),
+		Limits:  limits{Requests: 100, Burst: &ten},
+		Others:  []limits{{Requests: 5, Burst: &ten}},
+	}
+	if !reflect.DeepEqual(output, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, output)
+	}
+	if err := conl.Unmarshal([]byte("name = x\nport = 1\nlimits\n  requests = 1\n"), &output); err != nil || output.Name != "x" || output.Port != 1 || output.Limits.Requests != 1 {
+		t.Fatalf("unexpected result %#v, %v", output, err)
+	}
+
+	output = config{Port: 9000, Limits: limits{Requests: 5}}
+	if err := conl.Unmarshal([]byte("name = x\nlimits\n  burst\n"), &output); err != nil || output.Port != 9000 || output.Limits.Requests != 5 || *output.Limits.Burst != 10 {
+		t.Fatalf("unexpected result %#v, %v", output, err)
+	}
+
+	type invalid struct {
+		Count int `conl:"count,default=many"`
+	}
+	expectedErr := "invalid default for conl_test.invalid.Count in tag `conl:\"count,default=many\"`: "
+	for _, input := range []string{"", "a = b\ncount\n"} {
+		err := conl.UnmarshalWithOptions([]byte(input), &invalid{}, conl.UnmarshalOptions{IgnoreUnknownFields: true})
+		if err == nil || !strings.HasPrefix(err.Error(), expectedErr) {
+			t.Fatalf("expected error, got %v", err)
+		}
+	}
+}
+
+func TestInferTypes(t *testing.T) {
+	input := "a = 1\nb = -2.5e3\nc = true\nd = yes\ne = 99999999999999999999\nf = .5\ng = 0x10\nh\n  = False\n  = +3\n"
+
+	var output any
+	if err := conl.UnmarshalWithOptions([]byte(input), &output, conl.UnmarshalOptions{InferTypes: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"a": int64(1), "b": -2500.0, "c": true, "d": "yes", "e": "99999999999999999999", "f": 0.5, "g": "0x10",
+		"h": []any{"False", int64(3)},
+	}
+	if !reflect.DeepEqual(output, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, output)
+	}
+
+	output = nil
+	if err := conl.UnmarshalWithOptions([]byte(input), &output, conl.UnmarshalOptions{UseNumber: true}); err != nil {
+		t.Fatal(err)
+	}
+	m := output.(map[string]any)
+	if m["a"] != conl.Number("1") || m["e"] != conl.Number("99999999999999999999") || m["c"] != true || m["g"] != "0x10" {
+		t.Fatalf("unexpected output %#v", output)
+	}
+	if f, err := m["b"].(conl.Number).Float64(); err != nil || f != -2500 {
+		t.Fatalf("unexpected float %v, %v", f, err)
+	}
+	if _, err := m["e"].(conl.Number).Int64(); err == nil {
+		t.Fatalf("expected out of range error")
+	}
+
+	var defaults struct {
+		I any `conl:"i,default=5"`
+	}
+	if err := conl.UnmarshalWithOptions([]byte(""), &defaults, conl.UnmarshalOptions{InferTypes: true}); err != nil || defaults.I != int64(5) {
+		t.Fatalf("expected default to be inferred, got %#v, %v", defaults.I, err)
+	}
+
+	output = nil
+	if err := conl.Unmarshal([]byte(input), &output); err != nil || output.(map[string]any)["a"] != "1" {
+		t.Fatalf("expected strings by default, got %#v, %v", output, err)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	type server struct {
+		Host string `conl:"host,required"`
+		Port uint16 `conl:"port"`
+	}
+	type config struct {
+		Servers []server       `conl:"servers"`
+		Retries int            `conl:"retries"`
+		Limits  map[int]string `conl:"limits"`
+		Pair    [1]bool        `conl:"pair"`
+	}
+	input := `servers
+  =
+    host = a
+    port = 80
+  =
+    host = b
+  =
+    port = http
+    tyme = 5
+retries = many
+limits
+  one = 1
+pair
+  = true
+  = false
+`
+	var output config
+	err := conl.Unmarshal([]byte(input), &output)
+	var te *conl.TypeError
+	if !errors.As(err, &te) || te.Lno != 8 || te.Path != "servers[2].port" || te.Type != reflect.TypeFor[uint16]() ||
+		err.Error() != `8: servers[2].port: strconv.ParseUint: parsing "http": invalid syntax` {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Fatalf("expected error to wrap a *strconv.NumError, got %#v", err)
+	}
+
+	output = config{}
+	err = conl.UnmarshalWithOptions([]byte(input), &output, conl.UnmarshalOptions{AllErrors: true})
+	expected := []struct {
+		lno  int
+		path string
+		msg  string
+	}{
+		{8, "servers[2].port", `8: servers[2].port: strconv.ParseUint: parsing "http": invalid syntax`},
+		{9, "servers[2].tyme", "9: unknown field servers[2].tyme"},
+		{7, "servers[2].host", "7: missing required field servers[2].host"},
+		{10, "retries", `10: retries: strconv.ParseInt: parsing "many": invalid syntax`},
+		{12, "limits.one", `12: limits.one: strconv.ParseInt: parsing "one": invalid syntax`},
+		{15, "pair[1]", "15: pair[1]: too many elements, limit 1"},
+	}
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), err)
+	}
+	for i, e := range errs {
+		lno, path := 0, ""
+		switch e := e.(type) {
+		case *conl.TypeError:
+			lno, path = e.Lno, e.Path
+		case *conl.UnknownFieldError:
+			lno, path = e.Lno, e.Path
+		case *conl.MissingFieldError:
+			lno, path = e.Lno, e.Path
+		}
+		if lno != expected[i].lno || path != expected[i].path || e.Error() != expected[i].msg {
+			t.Errorf("%d: expected %v %q %q, got %#v", i, expected[i].lno, expected[i].path, expected[i].msg, e)
+		}
+	}
+	if output.Servers[1].Host != "b" || output.Pair[0] != true {
+		t.Fatalf("expected valid values to be decoded, got %#v", output)
+	}
+
+	var invalid struct {
+		Servers []struct {
+			Other []string `conl:",remaining"`
+		} `conl:"servers"`
+	}
+	err = conl.Unmarshal([]byte("servers\n  =\n    a = b\n"), &invalid)
+	if !errors.As(err, &te) || te.Lno != 3 || te.Path != "servers[0]" || te.Type != reflect.TypeFor[[]string]() ||
+		err.Error() != "3: servers[0]: remaining field must be a map with string keys or a conl.Value, not []string" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
+
+func TestNamingPolicy(t *testing.T) {
+	type schema struct {
+		RequiredKeys  []string
+		AnyOf         []string
+		HTTPServerURL string
+		Name          string `conl:"Name"`
+	}
+	input := schema{RequiredKeys: []string{"a"}, AnyOf: []string{"b"}, HTTPServerURL: "c", Name: "d"}
+
+	for _, tt := range []struct {
+		naming   conl.NamingPolicy
+		expected string
+	}{
+		{conl.DefaultNaming, "RequiredKeys\n  = a\nAnyOf\n  = b\nHTTPServerURL = c\nName = d\n"},
+		{conl.SpaceCase, "required keys\n  = a\nany of\n  = b\nhttp server url = c\nName = d\n"},
+		{conl.KebabCase, "required-keys\n  = a\nany-of\n  = b\nhttp-server-url = c\nName = d\n"},
+		{conl.SnakeCase, "required_keys\n  = a\nany_of\n  = b\nhttp_server_url = c\nName = d\n"},
+		{conl.CaseInsensitive, "RequiredKeys\n  = a\nAnyOf\n  = b\nHTTPServerURL = c\nName = d\n"},
+	} {
+		bytes, err := conl.MarshalWithOptions(input, conl.MarshalOptions{Naming: tt.naming})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bytes) != tt.expected {
+			t.Fatalf("%d: expected:\n%s\ngot:\n%s", tt.naming, tt.expected, bytes)
+		}
+
+		var output schema
+		if err := conl.UnmarshalWithOptions(bytes, &output, conl.UnmarshalOptions{Naming: tt.naming}); err != nil {
+			t.Fatalf("%d: %v", tt.naming, err)
+		}
+		if !reflect.DeepEqual(input, output) {
+			t.Fatalf("%d: expected %#v, got %#v", tt.naming, input, output)
+		}
+	}
+
+	var output schema
+	if err := conl.Unmarshal([]byte("required keys\n  = a\n"), &output); err == nil || err.Error() != "1: unknown field required keys" {
+		t.Fatalf("expected error, got %v", err)
+	}
+	if err := conl.UnmarshalWithOptions([]byte("requiredKEYS\n  = a\nNAME = b\n"), &output, conl.UnmarshalOptions{Naming: conl.CaseInsensitive}); err != nil || output.RequiredKeys[0] != "a" || output.Name != "b" {
+		t.Fatalf("unexpected output %#v, %v", output, err)
+	}
+}
+
+func TestCompositeKeys(t *testing.T) {
+	type placement struct {
+		Region string
+		Tier   int
+	}
+	input := map[placement]string{
+		{"us-east", 2}:  "a",
+		{"eu, west", 1}: "b",
+		{"", 3}:         "c",
+	}
+	bytes, err := conl.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "\"\\\"\\\", 3\" = c\n\"\\\"eu, west\\\", 1\" = b\nus-east, 2 = a\n"
+	if string(bytes) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, bytes)
+	}
+	output := map[placement]string{}
+	if err := conl.Unmarshal(bytes, &output); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(input, output) {
+		t.Fatalf("expected %#v, got %#v", input, output)
+	}
+
+	arrays := map[[2]float64]bool{}
+	if err := conl.Unmarshal([]byte("1.5,2 = true\n"), &arrays); err != nil || !arrays[[2]float64{1.5, 2}] {
+		t.Fatalf("unexpected output %#v, %v", arrays, err)
+	}
+	if bytes, err := conl.Marshal(arrays); err != nil || string(bytes) != "1.5, 2 = true\n" {
+		t.Fatalf("unexpected output %q, %v", bytes, err)
+	}
+
+	for _, input := range []string{"us-east = a\n", "us-east, 2, 3 = a\n", "us-east, two = a\n", "\"\\\"a\\\" b, 2\" = a\n"} {
+		if err := conl.Unmarshal([]byte(input), &output); err == nil {
+			t.Fatalf("%q: expected error", input)
+		}
+	}
+}
+
+func TestMultiline(t *testing.T) {
+	type template struct {
+		Query  conl.Multiline `conl:"query"`
+		Body   conl.Multiline `conl:"body,hint=json"`
+		Name   conl.Multiline `conl:"name"`
+		Params []conl.Multiline
+	}
+	input := "query = \"\"\"sql\n  select *\n  from users\nbody = \"\"\"\n  {}\nname = a\nParams\n  = \"\"\"txt\n    b\n"
+
+	var output template
+	if err := conl.Unmarshal([]byte(input), &output); err != nil {
+		t.Fatal(err)
+	}
+	expected := template{
+		Query:  conl.Multiline{Hint: "sql", Content: "select *\nfrom users"},
+		Body:   conl.Multiline{Content: "{}"},
+		Name:   conl.Multiline{Content: "a"},
+		Params: []conl.Multiline{{Hint: "txt", Content: "b"}},
+	}
+	if !reflect.DeepEqual(output, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, output)
+	}
+
+	bytes, err := conl.Marshal(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedOutput := "query = \"\"\"sql\n  select *\n  from users\nbody = \"\"\"json\n  {}\nname = a\nParams\n  = \"\"\"txt\n    b\n"
+	if string(bytes) != expectedOutput {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expectedOutput, bytes)
+	}
+
+	if err := conl.Unmarshal([]byte("query\n  a = b\n"), &output); err == nil || err.Error() != "2: query: expected value" {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+type benchmarkServer struct {
+	Name    string        `conl:"name"`
+	Port    int           `conl:"port"`
+	Enabled bool          `conl:"enabled"`
+	Timeout time.Duration `conl:"timeout,default=30s"`
+	Tags    []string      `conl:"tags"`
+}
+
+func benchmarkDocument(n int) []byte {
+	var b strings.Builder
+	b.WriteString("servers\n")
+	for i := range n {
+		fmt.Fprintf(&b, "  =\n    name = server-%d\n    port = %d\n    enabled = true\n    tags\n      = a\n      = b\n", i, 8000+i%1000)
+	}
+	return []byte(b.String())
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		data := benchmarkDocument(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for b.Loop() {
+				var output struct {
+					Servers []benchmarkServer `conl:"servers"`
+				}
+				if err := conl.Unmarshal(data, &output); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		var input struct {
+			Servers []benchmarkServer `conl:"servers"`
+		}
+		if err := conl.Unmarshal(benchmarkDocument(n), &input); err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := conl.Marshal(input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package conl
+
+import (
+	"errors"
+	"reflect"
+)
+
+// A Multiline is a scalar along with its multiline hint. Unmarshalling into
+// a Multiline records the hint of a multiline scalar (for example "sql" for
+// a value introduced by `"""sql`), and marshalling a Multiline writes the
+// content as a multiline scalar with that hint.
+//
+// The hint is empty if the scalar was not multiline, or had no hint. When
+// marshalling, a Multiline with no hint is written in the same way as a string
+// (using the "hint=" option of the field, if any), and content that cannot be
+// represented as a multiline scalar is quoted, losing the hint.
+type Multiline struct {
+	Hint    string
+	Content string
+}
+
+var multilineType = reflect.TypeFor[Multiline]()
+
+func (d *decodeState) unmarshalMultiline(v *Multiline) error {
+	token := d.nextToken()
+	if token.Kind == Scalar {
+		v.Hint, v.Content = d.hint, token.Content
+		return nil
+	}
+	return d.typeError(token.Lno, reflect.ValueOf(v).Elem(), errors.New("expected value"))
+}
+
+// marshalMultiline is the equivalent of marshalValue for a [Multiline].
+func (e *encodeState) marshalMultiline(v *Multiline, indent, hint string) (string, bool, error) {
+	if v.Hint != "" {
+		hint = v.Hint
+	}
+	return quoteValue(v.Content, indent+e.opts.Indent, hint), true, nil
+}
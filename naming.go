@@ -0,0 +1,71 @@
+package conl
+
+import (
+	"strings"
+	"unicode"
+)
+
+// A NamingPolicy determines the CONL key used for struct fields that do not
+// have a name in their tag. Fields with a tagged name always use that name.
+type NamingPolicy int
+
+const (
+	// DefaultNaming writes the Go field name (for example "MaxSize"), and
+	// when unmarshalling accepts either the Go field name or its snake_case
+	// equivalent ("max_size").
+	DefaultNaming NamingPolicy = iota
+	// SpaceCase uses lower case words separated by spaces, for example "max size".
+	SpaceCase
+	// KebabCase uses lower case words separated by hyphens, for example "max-size".
+	KebabCase
+	// SnakeCase uses lower case words separated by underscores, for example "max_size".
+	SnakeCase
+	// CaseInsensitive writes the Go field name, and when unmarshalling
+	// matches keys to field names (including tagged names) ignoring case.
+	CaseInsensitive
+)
+
+// fieldName returns the CONL key for an untagged field called name.
+func (p NamingPolicy) fieldName(name string) string {
+	switch p {
+	case SpaceCase:
+		return strings.Join(splitWords(name), " ")
+	case KebabCase:
+		return strings.Join(splitWords(name), "-")
+	case SnakeCase:
+		return strings.Join(splitWords(name), "_")
+	}
+	return name
+}
+
+// splitWords splits a Go identifier into lower case words. A new word starts
+// at an underscore, at an upper case letter following a lower case letter or
+// digit, and at the last upper case letter of an acronym. For example
+// "HTTPServerURL" is split into "http", "server" and "url".
+func splitWords(name string) []string {
+	words := []string{}
+	word := []rune{}
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '_' {
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+			word = word[:0]
+			continue
+		}
+		if i > 0 && len(word) > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+				words = append(words, string(word))
+				word = word[:0]
+			}
+		}
+		word = append(word, unicode.ToLower(r))
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
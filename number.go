@@ -0,0 +1,54 @@
+package conl
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+)
+
+// A Number is a numeric scalar that retains its original text. It is used
+// when unmarshalling into an interface with [UnmarshalOptions].UseNumber set.
+type Number string
+
+// String returns the text of the number.
+func (n Number) String() string {
+	return string(n)
+}
+
+// Float64 returns the number as a float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Int64 returns the number as an int64.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+var numberRegexp = regexp.MustCompile(`^[-+]?(?:[0-9]+(?:\.[0-9]*)?|\.[0-9]+)(?:[eE][-+]?[0-9]+)?$`)
+
+// inferScalar returns the value to use for a scalar when unmarshalling into
+// an interface with InferTypes or UseNumber set.
+func (d *decodeState) inferScalar(s string) any {
+	switch {
+	case s == "true":
+		return true
+	case s == "false":
+		return false
+	case !numberRegexp.MatchString(s):
+		return s
+	case d.opts.UseNumber:
+		return Number(s)
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err == nil {
+		return i
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return s
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package conl
+
+import (
+	"iter"
+	"reflect"
+	"slices"
+)
+
+// An OrderedMap is a map that remembers the order in which keys were added.
+//
+// When unmarshalling into an OrderedMap, keys are added in the order they
+// appear in the document; and when marshalling, keys are written in the order
+// they were added (instead of being sorted as for a Go map).
+//
+// The zero value is an empty map ready to use.
+type OrderedMap[K comparable, V any] struct {
+	keys   []K
+	values map[K]V
+}
+
+// Get returns the value for key, and whether it was present.
+func (m *OrderedMap[K, V]) Get(key K) (V, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Set sets the value for key. If the key is new it is added at the end,
+// otherwise its position is unchanged.
+func (m *OrderedMap[K, V]) Set(key K, value V) {
+	if m.values == nil {
+		m.values = make(map[K]V)
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Delete removes key from the map, if it is present.
+func (m *OrderedMap[K, V]) Delete(key K) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	m.keys = slices.DeleteFunc(m.keys, func(k K) bool { return k == key })
+}
+
+// Len returns the number of keys in the map.
+func (m *OrderedMap[K, V]) Len() int {
+	return len(m.keys)
+}
+
+// Keys iterates over the keys of the map in order.
+func (m *OrderedMap[K, V]) Keys() iter.Seq[K] {
+	return slices.Values(m.keys)
+}
+
+// All iterates over the keys and values of the map in order.
+func (m *OrderedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, k := range m.keys {
+			if !yield(k, m.values[k]) {
+				return
+			}
+		}
+	}
+}
+
+// orderedMap is implemented by *OrderedMap so that it can be unmarshalled
+// via reflection.
+type orderedMap interface {
+	types() (key, value reflect.Type)
+	setValue(key, value reflect.Value)
+}
+
+// orderedEntries is implemented by OrderedMap so that it can be marshalled
+// via reflection.
+type orderedEntries interface {
+	entries() iter.Seq2[any, any]
+}
+
+func (m *OrderedMap[K, V]) types() (key, value reflect.Type) {
+	return reflect.TypeFor[K](), reflect.TypeFor[V]()
+}
+
+func (m *OrderedMap[K, V]) setValue(key, value reflect.Value) {
+	var k K
+	var v V
+	reflect.ValueOf(&k).Elem().Set(key)
+	reflect.ValueOf(&v).Elem().Set(value)
+	m.Set(k, v)
+}
+
+func (m OrderedMap[K, V]) entries() iter.Seq2[any, any] {
+	return func(yield func(any, any) bool) {
+		for k, v := range m.All() {
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package conl_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ConradIrwin/conl-go"
+)
+
+func TestOrderedMap(t *testing.T) {
+	input := `stages
+  test
+    = go test
+  build
+    = go build
+  deploy
+`
+	var output struct {
+		Stages conl.OrderedMap[string, []string]
+	}
+	if err := conl.Unmarshal([]byte(input), &output); err != nil {
+		t.Fatal(err)
+	}
+	if keys := slices.Collect(output.Stages.Keys()); !slices.Equal(keys, []string{"test", "build", "deploy"}) {
+		t.Fatalf("unexpected keys: %#v", keys)
+	}
+	if v, ok := output.Stages.Get("build"); !ok || !slices.Equal(v, []string{"go build"}) {
+		t.Fatalf("unexpected value: %#v", v)
+	}
+
+	output.Stages.Delete("test")
+	output.Stages.Set("build", []string{"make"})
+	output.Stages.Set("lint", []string{"go vet"})
+	if output.Stages.Len() != 3 {
+		t.Fatalf("unexpected length: %d", output.Stages.Len())
+	}
+
+	bytes, err := conl.Marshal(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Stages\n  build\n    = make\n  deploy\n    ; empty\n  lint\n    = go vet\n"
+	if string(bytes) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, bytes)
+	}
+
+	var m conl.OrderedMap[int, any]
+	if err := conl.Unmarshal([]byte("3 = c\n1\n  = a\n2"), &m); err != nil {
+		t.Fatal(err)
+	}
+	if keys := slices.Collect(m.Keys()); !slices.Equal(keys, []int{3, 1, 2}) {
+		t.Fatalf("unexpected keys: %#v", keys)
+	}
+	bytes, err = conl.Marshal(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bytes) != "3 = c\n1\n  = a\n2 ; nil\n" {
+		t.Fatalf("unexpected output: %q", bytes)
+	}
+}
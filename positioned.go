@@ -0,0 +1,65 @@
+package conl
+
+import "reflect"
+
+// A Positioned is a value along with its position in the document.
+//
+// When unmarshalling into a Positioned, Value is decoded as usual and the
+// positions are recorded, so that errors found after decoding can refer to the
+// right line. When marshalling, only Value is written.
+type Positioned[T any] struct {
+	Value T
+	// KeyLno and KeyCol are the position of the map key or list item that
+	// contains the value. They are zero for the top level of the document.
+	KeyLno, KeyCol int
+	// Lno and Col are the position of the value. For maps and lists this is
+	// the position of the first entry, and for an empty value it is the
+	// position of its key. A value set from a "default=" option has the
+	// position of its key, or no position if the key is absent.
+	Lno, Col int
+}
+
+// positioned is implemented by *Positioned so that it can be unmarshalled
+// via reflection.
+type positioned interface {
+	target() reflect.Value
+	setPosition(keyLno, keyCol, lno, col int)
+}
+
+// positionedValue is implemented by Positioned so that it can be marshalled
+// via reflection.
+type positionedValue interface {
+	value() any
+}
+
+func (p *Positioned[T]) target() reflect.Value {
+	return reflect.ValueOf(&p.Value).Elem()
+}
+
+func (p *Positioned[T]) setPosition(keyLno, keyCol, lno, col int) {
+	p.KeyLno, p.KeyCol, p.Lno, p.Col = keyLno, keyCol, lno, col
+}
+
+func (p Positioned[T]) value() any {
+	return p.Value
+}
+
+func (d *decodeState) unmarshalPositioned(p positioned) error {
+	keyLno, keyCol := d.keyLno, d.keyCol
+	if keyCol == 0 {
+		keyLno = 0
+	}
+	lno, col := keyLno, keyCol
+	switch token := d.peekToken(); token.Kind {
+	case Scalar:
+		lno, col = token.Lno, token.Col
+	case Indent:
+		// the indent token is on the line of the first entry, and its
+		// content is the indentation before it.
+		lno, col = token.Lno, len(token.Content)+1
+	case MapKey, ListItem:
+		lno, col = token.Lno, token.Col
+	}
+	p.setPosition(keyLno, keyCol, lno, col)
+	return d.unmarshalValue(p.target())
+}
@@ -0,0 +1,83 @@
+package conl_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ConradIrwin/conl-go"
+)
+
+func TestPositioned(t *testing.T) {
+	type service struct {
+		Name  string                    `conl:"name"`
+		Port  conl.Positioned[int]      `conl:"port"`
+		Tags  conl.Positioned[[]string] `conl:"tags"`
+		Extra conl.Positioned[*string]  `conl:"extra"`
+		Empty conl.Positioned[int]      `conl:"empty"`
+	}
+	type config struct {
+		Services []conl.Positioned[service] `conl:"services"`
+	}
+	input := `services
+  =
+    name = web
+    port = 80
+    tags
+      ; comment
+      = a
+    extra
+`
+	var output conl.Positioned[config]
+	if err := conl.Unmarshal([]byte(input), &output); err != nil {
+		t.Fatal(err)
+	}
+	svc := output.Value.Services[0]
+	for _, tt := range []struct {
+		name     string
+		actual   [4]int
+		expected [4]int
+	}{
+		{"config", [4]int{output.KeyLno, output.KeyCol, output.Lno, output.Col}, [4]int{0, 0, 1, 1}},
+		{"service", [4]int{svc.KeyLno, svc.KeyCol, svc.Lno, svc.Col}, [4]int{2, 3, 3, 5}},
+		{"port", [4]int{svc.Value.Port.KeyLno, svc.Value.Port.KeyCol, svc.Value.Port.Lno, svc.Value.Port.Col}, [4]int{4, 5, 4, 12}},
+		{"tags", [4]int{svc.Value.Tags.KeyLno, svc.Value.Tags.KeyCol, svc.Value.Tags.Lno, svc.Value.Tags.Col}, [4]int{5, 5, 7, 7}},
+		{"extra", [4]int{svc.Value.Extra.KeyLno, svc.Value.Extra.KeyCol, svc.Value.Extra.Lno, svc.Value.Extra.Col}, [4]int{8, 5, 8, 5}},
+		{"empty", [4]int{svc.Value.Empty.KeyLno, svc.Value.Empty.KeyCol, svc.Value.Empty.Lno, svc.Value.Empty.Col}, [4]int{}},
+	} {
+		if tt.actual != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, tt.actual)
+		}
+	}
+	if svc.Value.Port.Value != 80 || !reflect.DeepEqual(svc.Value.Tags.Value, []string{"a"}) || svc.Value.Extra.Value != nil {
+		t.Fatalf("unexpected values %#v", svc.Value)
+	}
+
+	var defaults struct {
+		Absent  conl.Positioned[int] `conl:"absent,default=1"`
+		Present conl.Positioned[int] `conl:"present,default=2"`
+	}
+	if err := conl.Unmarshal([]byte("; comment\npresent\n"), &defaults); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name     string
+		actual   conl.Positioned[int]
+		expected conl.Positioned[int]
+	}{
+		{"absent", defaults.Absent, conl.Positioned[int]{Value: 1}},
+		{"present", defaults.Present, conl.Positioned[int]{Value: 2, KeyLno: 2, KeyCol: 1, Lno: 2, Col: 1}},
+	} {
+		if tt.actual != tt.expected {
+			t.Errorf("%s: expected %#v, got %#v", tt.name, tt.expected, tt.actual)
+		}
+	}
+
+	bytes, err := conl.Marshal(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "services\n  =\n    name = web\n    port = 80\n    tags\n      = a\n    extra ; nil\n    empty = 0\n"
+	if string(bytes) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, bytes)
+	}
+}
@@ -0,0 +1,91 @@
+package conl
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+)
+
+// A Match is a value found by [Query].
+type Match struct {
+	// Path is the path of the value, with any wildcards replaced by
+	// the keys or indexes that matched.
+	Path string
+	// Lno and Col are the position of the key or list item for the value
+	// (or of the value itself, if the path is empty).
+	Lno, Col int
+	Value    *Value
+}
+
+// Query parses the document and returns the values that match path, in
+// document order.
+//
+// A path is a sequence of segments separated by "/". Each segment is either
+// a map key, a list index, "*" to match any single key or index, or "**"
+// to match any number of levels (including none). For example:
+// "servers/*/port", "stages/0/name" or "**/image". Keys that contain a "/",
+// or that are exactly "*" or "**", cannot be addressed. An empty path matches
+// the whole document.
+//
+// An error is returned if the document is not valid CONL, or if path is
+// not a valid path.
+func Query(data []byte, path string) ([]Match, error) {
+	var v Value
+	if err := Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v.Query(path)
+}
+
+// Query returns the values within v that match path.
+// See [Query] for the syntax of path.
+func (v *Value) Query(path string) ([]Match, error) {
+	segments, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	q := &query{seen: map[*Value]bool{}}
+	q.match(v, "", v.Lno, v.Col, segments)
+	slices.SortStableFunc(q.matches, func(a, b Match) int {
+		return cmp.Or(cmp.Compare(a.Lno, b.Lno), cmp.Compare(a.Col, b.Col))
+	})
+	return q.matches, nil
+}
+
+type query struct {
+	matches []Match
+	seen    map[*Value]bool
+}
+
+func (q *query) match(v *Value, path string, lno, col int, segments []string) {
+	if len(segments) == 0 {
+		if !q.seen[v] {
+			q.seen[v] = true
+			q.matches = append(q.matches, Match{Path: path, Lno: lno, Col: col, Value: v})
+		}
+		return
+	}
+
+	segment := segments[0]
+	if segment == "**" {
+		q.match(v, path, lno, col, segments[1:])
+	}
+	for i, e := range v.Entries {
+		key := e.Key
+		if v.Kind == ListValue {
+			key = strconv.Itoa(i)
+		}
+		if segment != "*" && segment != "**" && segment != key {
+			continue
+		}
+		childPath := key
+		if path != "" {
+			childPath = path + "/" + key
+		}
+		if segment == "**" {
+			q.match(&e.Value, childPath, e.Lno, e.Col, segments)
+		} else {
+			q.match(&e.Value, childPath, e.Lno, e.Col, segments[1:])
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package conl_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/ConradIrwin/conl-go"
+)
+
+func TestQuery(t *testing.T) {
+	input := `servers
+  web
+    port = 80
+    image = nginx
+  db
+    port = 5432
+stages
+  =
+    name = build
+    image = golang
+  =
+    name = test
+image = alpine
+`
+	for _, tt := range []struct {
+		path     string
+		expected []string
+	}{
+		{"servers/*/port", []string{"servers/web/port:3 80", "servers/db/port:6 5432"}},
+		{"stages/0/name", []string{"stages/0/name:9 build"}},
+		{"stages/1/name", []string{"stages/1/name:12 test"}},
+		{"**/image", []string{"servers/web/image:4 nginx", "stages/0/image:10 golang", "image:13 alpine"}},
+		{"**/**/image", []string{"servers/web/image:4 nginx", "stages/0/image:10 golang", "image:13 alpine"}},
+		{"stages/*", []string{"stages/0:8 MapValue", "stages/1:11 MapValue"}},
+		{"stages/2", []string{}},
+		{"servers/web/port/x", []string{}},
+	} {
+		matches, err := conl.Query([]byte(input), tt.path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.path, err)
+		}
+		actual := []string{}
+		for _, m := range matches {
+			value := m.Value.Scalar
+			if m.Value.Kind != conl.ScalarValue {
+				value = m.Value.Kind.String()
+			}
+			actual = append(actual, fmt.Sprintf("%s:%d %s", m.Path, m.Lno, value))
+		}
+		if !slices.Equal(actual, tt.expected) {
+			t.Errorf("%s: expected %#v, got %#v", tt.path, tt.expected, actual)
+		}
+	}
+
+	if _, err := conl.Query([]byte(input), "servers//port"); err == nil || err.Error() != `invalid path "servers//port"` {
+		t.Fatalf("expected error, got %v", err)
+	}
+	if _, err := conl.Query([]byte("a = \"b"), "a"); err == nil || err.Error() != "1: unclosed quotes" {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package conl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ValueKind identifies the kind of a [Value].
+type ValueKind int
+
+const (
+	// EmptyValue is a key or list item that has no value.
+	EmptyValue ValueKind = iota
+	// ScalarValue is a single (possibly multiline) string.
+	ScalarValue
+	// MapValue is a sequence of key-value pairs.
+	MapValue
+	// ListValue is a sequence of list items.
+	ListValue
+)
+
+func (k ValueKind) String() string {
+	switch k {
+	case EmptyValue:
+		return "EmptyValue"
+	case ScalarValue:
+		return "ScalarValue"
+	case MapValue:
+		return "MapValue"
+	case ListValue:
+		return "ListValue"
+	}
+	return fmt.Sprintf("ValueKind(%d)", int(k))
+}
+
+// A Value is a generic representation of a CONL value. Unlike unmarshalling
+// into an `any`, it retains the order of keys, the position of each value,
+// comments, and the distinction between an empty string and no value.
+//
+// A Value can be used as the target of [Unmarshal] (either for the whole
+// document or for any field within it), and [Marshal] will write it back out
+// including its comments and multiline hints.
+type Value struct {
+	Kind ValueKind
+	// Scalar is the content of a [ScalarValue].
+	Scalar string
+	// Hint is the multiline hint of a [ScalarValue], if any.
+	Hint string
+	// Entries are the entries of a [MapValue] or [ListValue] in document order.
+	Entries []*ValueEntry
+	// Lno and Col are the position of the value. For maps and lists this is
+	// the position of the first entry, and for an empty value it is the position
+	// of its key. They are zero for an empty document.
+	Lno, Col int
+	// Comments are any comments at the end of a map or list, after the last entry.
+	Comments []string
+}
+
+// A ValueEntry is a key-value pair in a map, or an item in a list.
+type ValueEntry struct {
+	// Key is the key of the entry, or "" for list items.
+	Key   string
+	Value Value
+	// Lno and Col are the position of the key or "=".
+	Lno, Col int
+	// Comments are the comments on the lines before the entry, followed by
+	// any comment on the same line. Comment content excludes the leading ";".
+	Comments []string
+}
+
+var valueType = reflect.TypeFor[Value]()
+
+// Get returns the value of key in a map, or nil if v is not a map
+// or does not contain the key. If the key is repeated, the first
+// occurrence is returned.
+func (v *Value) Get(key string) *Value {
+	if v.Kind != MapValue {
+		return nil
+	}
+	for _, e := range v.Entries {
+		if e.Key == key {
+			return &e.Value
+		}
+	}
+	return nil
+}
+
+// Index returns the i'th item in a list, or nil if v is not a list
+// or i is out of range.
+func (v *Value) Index(i int) *Value {
+	if v.Kind != ListValue || i < 0 || i >= len(v.Entries) {
+		return nil
+	}
+	return &v.Entries[i].Value
+}
+
+// Len returns the number of entries in a map or list, and 0 otherwise.
+func (v *Value) Len() int {
+	return len(v.Entries)
+}
+
+func (d *decodeState) unmarshalConlValue(v *Value) error {
+	if d.keepComments == 0 {
+		d.comments, d.lastEntry = nil, nil
+	}
+	d.keepComments++
+	defer func() { d.keepComments-- }()
+
+	*v = Value{}
+	for {
+		token := d.nextToken()
+		switch token.Kind {
+		case Indent:
+			continue
+		case NoValue:
+			v.Lno, v.Col = token.Lno, token.Col
+			if d.lastEntry != nil {
+				v.Lno, v.Col = d.lastEntry.Lno, d.lastEntry.Col
+			}
+			return nil
+		case Scalar:
+			v.Kind, v.Scalar, v.Hint = ScalarValue, token.Content, d.hint
+			v.Lno, v.Col = token.Lno, token.Col
+			return nil
+		case MapKey, ListItem:
+			entry := &ValueEntry{Lno: token.Lno, Col: token.Col}
+			if token.Kind == MapKey {
+				entry.Key = token.Content
+			}
+			if v.Kind == EmptyValue {
+				v.Kind, v.Lno, v.Col = ListValue, token.Lno, token.Col
+				if token.Kind == MapKey {
+					v.Kind = MapValue
+				}
+			}
+			entry.Comments = d.takeComments(0)
+			v.Entries = append(v.Entries, entry)
+			d.lastEntry = entry
+			if err := d.unmarshalConlValue(&entry.Value); err != nil {
+				return err
+			}
+		case Outdent:
+			v.Comments = d.takeComments(v.Col)
+			return nil
+		default:
+			return d.typeError(token.Lno, reflect.ValueOf(v).Elem(), fmt.Errorf("unexpected %v", token.Kind))
+		}
+	}
+}
+
+// takeComments returns the pending comments that start at or after col.
+// Comments on the same line as the most recent entry are attached to that
+// entry instead.
+func (d *decodeState) takeComments(col int) []string {
+	var result []string
+	i := 0
+	for ; i < len(d.comments); i++ {
+		c := d.comments[i]
+		if d.lastEntry != nil && c.Lno == d.lastEntry.Lno {
+			d.lastEntry.Comments = append(d.lastEntry.Comments, c.Content)
+			continue
+		}
+		if c.Col < col {
+			break
+		}
+		result = append(result, c.Content)
+	}
+	d.comments = d.comments[i:]
+	return result
+}
+
+// marshalConlValue is the equivalent of marshalValue for a [Value].
+func (e *encodeState) marshalConlValue(v *Value, indent string) (string, bool, error) {
+	switch v.Kind {
+	case EmptyValue:
+		return "", false, nil
+	case ScalarValue:
+		return quoteValue(v.Scalar, indent+e.opts.Indent, v.Hint), true, nil
+	}
+	section, err := e.marshalConlSection(v, indent+e.opts.Indent)
+	if err != nil {
+		return "", false, err
+	}
+	return "\n" + section, false, nil
+}
+
+// marshalConlSection is the equivalent of marshalSection for a [Value].
+func (e *encodeState) marshalConlSection(v *Value, indent string) (string, error) {
+	if v.Kind == ScalarValue {
+		return "", fmt.Errorf("unsupported type: %s", v.Kind)
+	}
+	strs, err := e.conlEntries(v, indent)
+	if err != nil {
+		return "", err
+	}
+	if len(strs) == 0 {
+		return "", nil
+	}
+	return e.joinSection(strs, indent), nil
+}
+
+// conlEntries returns the entries and trailing comments of a map or list
+// [Value], without joining them into a section.
+func (e *encodeState) conlEntries(v *Value, indent string) ([]string, error) {
+	strs := []string{}
+	for _, entry := range v.Entries {
+		key := "="
+		if v.Kind == MapValue {
+			key = quoteString(entry.Key)
+		}
+		value, eq, err := e.marshalConlValue(&entry.Value, indent)
+		if err != nil {
+			return nil, err
+		}
+		lines := []string{}
+		for _, c := range entry.Comments {
+			lines = append(lines, ";"+c)
+		}
+		switch {
+		case eq && v.Kind == ListValue:
+			lines = append(lines, "= "+value)
+		case eq:
+			lines = append(lines, key+" = "+value)
+		default:
+			lines = append(lines, key+value)
+		}
+		strs = append(strs, strings.Join(lines, "\n"+indent))
+	}
+	for _, c := range v.Comments {
+		strs = append(strs, ";"+c)
+	}
+	return strs, nil
+}
@@ -0,0 +1,117 @@
+package conl_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ConradIrwin/conl-go"
+)
+
+func TestValue(t *testing.T) {
+	input := `; header
+b = 1 ; one
+a
+  ; before x
+  x = """sh
+    echo hi
+  y
+  ; end of a
+list
+  = one
+  =
+  = ""
+; footer
+`
+	var v conl.Value
+	if err := conl.Unmarshal([]byte(input), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Kind != conl.MapValue || v.Len() != 3 {
+		t.Fatalf("expected map with 3 entries, got %v with %d", v.Kind, v.Len())
+	}
+	keys := []string{}
+	for _, e := range v.Entries {
+		keys = append(keys, e.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"b", "a", "list"}) {
+		t.Fatalf("unexpected key order: %#v", keys)
+	}
+	if !reflect.DeepEqual(v.Entries[0].Comments, []string{" header", " one"}) {
+		t.Fatalf("unexpected comments: %#v", v.Entries[0].Comments)
+	}
+	if !reflect.DeepEqual(v.Comments, []string{" footer"}) {
+		t.Fatalf("unexpected trailing comments: %#v", v.Comments)
+	}
+
+	a := v.Get("a")
+	if a.Kind != conl.MapValue || a.Lno != 5 || a.Col != 3 {
+		t.Fatalf("unexpected a: %#v", a)
+	}
+	if !reflect.DeepEqual(a.Entries[0].Comments, []string{" before x"}) || !reflect.DeepEqual(a.Comments, []string{" end of a"}) {
+		t.Fatalf("unexpected comments in a: %#v %#v", a.Entries[0].Comments, a.Comments)
+	}
+	if x := a.Get("x"); x.Kind != conl.ScalarValue || x.Scalar != "echo hi" || x.Hint != "sh" {
+		t.Fatalf("unexpected x: %#v", x)
+	}
+	if y := a.Get("y"); y.Kind != conl.EmptyValue || y.Lno != 7 {
+		t.Fatalf("unexpected y: %#v", y)
+	}
+
+	list := v.Get("list")
+	if list.Kind != conl.ListValue || list.Len() != 3 {
+		t.Fatalf("unexpected list: %#v", list)
+	}
+	if list.Index(1).Kind != conl.EmptyValue || list.Index(2).Kind != conl.ScalarValue || list.Index(3) != nil {
+		t.Fatalf("unexpected list items: %#v", list.Entries)
+	}
+	if v.Get("missing") != nil || list.Get("one") != nil {
+		t.Fatalf("expected nil for missing keys")
+	}
+
+	output, err := conl.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `; header
+; one
+b = 1
+a
+  ; before x
+  x = """sh
+    echo hi
+  y
+  ; end of a
+list
+  = one
+  =
+  = ""
+; footer
+`
+	if string(output) != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+}
+
+func TestValueField(t *testing.T) {
+	var output struct {
+		Name  string
+		Extra conl.Value
+		Ptr   *conl.Value
+	}
+	input := "name = a\nextra ; note\n  = b\nptr\n"
+	if err := conl.Unmarshal([]byte(input), &output); err != nil {
+		t.Fatal(err)
+	}
+	if output.Name != "a" || output.Extra.Kind != conl.ListValue || output.Extra.Index(0).Scalar != "b" || output.Ptr != nil {
+		t.Fatalf("unexpected output: %#v", output)
+	}
+
+	bytes, err := conl.Marshal(map[string]any{"extra": &output.Extra})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bytes) != "extra\n  ; note\n  = b\n" {
+		t.Fatalf("unexpected marshal output: %q", bytes)
+	}
+}